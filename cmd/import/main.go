@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"admin_statistics_api/config"
+	"admin_statistics_api/models"
+	"admin_statistics_api/services"
+	"admin_statistics_api/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/joho/godotenv"
+)
+
+// row is a parsed input line waiting to be written. record holds the
+// original CSV fields, or the JSON line as its only field.
+type row struct {
+	line   int
+	record []string
+	tx     models.Transaction
+}
+
+// rejection is a line that could not be imported
+type rejection struct {
+	line   int
+	reason string
+	record []string
+}
+
+// rejectWriter serialises rejections from several workers into one file.
+// Each rejection is written as its line number and reason followed by the
+// original fields under the input's own header, so the file can be fixed
+// and imported again as it is.
+type rejectWriter struct {
+	mu     sync.Mutex
+	writer *csv.Writer
+	count  int
+}
+
+// start writes the header, given the columns of the input
+func (r *rejectWriter) start(columns []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writer.Write(append([]string{"line", "reason"}, columns...))
+}
+
+func (r *rejectWriter) reject(rej rejection) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writer.Write(append([]string{strconv.Itoa(rej.line), rej.reason}, rej.record...))
+	r.count++
+}
+
+// counters tracks the outcome of written rows
+type counters struct {
+	mu       sync.Mutex
+	created  int
+	replayed int
+	// firstDay and lastDay are the UTC days of the earliest and latest
+	// created rows, whose rollups are rebuilt once the import is done
+	firstDay time.Time
+	lastDay  time.Time
+}
+
+// addDay widens the range of days with created rows to include t; the
+// caller holds the lock
+func (c *counters) addDay(t time.Time) {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if c.firstDay.IsZero() || day.Before(c.firstDay) {
+		c.firstDay = day
+	}
+	if day.After(c.lastDay) {
+		c.lastDay = day
+	}
+}
+
+// Imports transaction dumps from CSV or newline-delimited JSON files.
+//
+//	go run ./cmd/import -file dump.csv
+//	go run ./cmd/import -file dump.ndjson -batch 5000 -workers 8
+//	go run ./cmd/import -file provider.csv -columns "userId=player,amount=stake,createdAt=ts"
+//
+// CSV files need a header row; columns are matched to the JSON field names
+// of models.TransactionRequest unless remapped with -columns. Rows that fail
+// parsing, validation or the write are listed in the reject file together
+// with their line number and reason. The rollups of the days that received
+// rows are rebuilt at the end.
+func main() {
+	filePath := flag.String("file", "", "CSV or NDJSON file to import (required)")
+	format := flag.String("format", "", "input format: csv or ndjson (default: from the file extension)")
+	batchSize := flag.Int("batch", 1000, "transactions per bulk write")
+	workers := flag.Int("workers", 4, "concurrent bulk writers")
+	rejectPath := flag.String("rejects", "", "reject file path (default: <file>.rejects.csv)")
+	columns := flag.String("columns", "", "CSV column mapping as field=column pairs, e.g. userId=player,amount=stake")
+	flag.Parse()
+
+	if *filePath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *batchSize < 1 || *workers < 1 {
+		log.Fatal("-batch and -workers must be at least 1")
+	}
+
+	if *format == "" {
+		switch strings.ToLower(filepath.Ext(*filePath)) {
+		case ".csv":
+			*format = "csv"
+		case ".ndjson", ".jsonl", ".json":
+			*format = "ndjson"
+		default:
+			log.Fatal("Cannot infer the format from the file extension, use -format")
+		}
+	}
+	if *rejectPath == "" {
+		*rejectPath = *filePath + ".rejects.csv"
+	}
+
+	mapping, err := parseColumnMapping(*columns)
+	if err != nil {
+		log.Fatal("Invalid -columns:", err)
+	}
+
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		fmt.Println("No .env file found, using default values")
+	}
+
+	input, err := os.Open(*filePath)
+	if err != nil {
+		log.Fatal("Failed to open input file:", err)
+	}
+	defer input.Close()
+
+	rejectFile, err := os.Create(*rejectPath)
+	if err != nil {
+		log.Fatal("Failed to create reject file:", err)
+	}
+	defer rejectFile.Close()
+
+	rejects := &rejectWriter{writer: csv.NewWriter(rejectFile)}
+
+	config.ConnectDatabase()
+	defer config.DisconnectDatabase()
+
+	// Rates are loaded once so that converting usdAmount does not cost a
+	// query per row
+	rates, err := services.PreloadRates(context.Background(), config.NewRateProvider(config.DB))
+	if err != nil {
+		log.Fatal("Failed to load exchange rates:", err)
+	}
+	currencies := config.LoadCurrencies(config.DB)
+	store := services.NewMongoTransactionStore(config.DB)
+	service := services.NewTransactionService(store, rates, currencies)
+	validate := utils.NewValidator(currencies)
+
+	fmt.Printf("Importing %s (%s) with batch size %d and %d workers...\n", *filePath, *format, *batchSize, *workers)
+	startTime := time.Now()
+
+	batches := make(chan []row, *workers)
+	var totals counters
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				writeBatch(service, batch, rejects, &totals)
+			}
+		}()
+	}
+
+	// Read and validate on this goroutine, handing full batches to workers
+	var batch []row
+	emit := func(r row) {
+		batch = append(batch, r)
+		if len(batch) >= *batchSize {
+			batches <- batch
+			batch = nil
+		}
+	}
+
+	var read int
+	switch *format {
+	case "csv":
+		read, err = readCSV(input, service, mapping, validate, emit, rejects)
+	case "ndjson":
+		read, err = readNDJSON(input, service, validate, emit, rejects)
+	default:
+		err = fmt.Errorf("unsupported format %q", *format)
+	}
+	if len(batch) > 0 {
+		batches <- batch
+	}
+	close(batches)
+	wg.Wait()
+
+	rejects.writer.Flush()
+	if flushErr := rejects.writer.Error(); flushErr != nil {
+		log.Printf("Failed to write reject file: %v", flushErr)
+	}
+	if err != nil {
+		log.Fatal("Import aborted:", err)
+	}
+
+	fmt.Printf("Read %d rows in %v: %d imported, %d already present, %d rejected\n",
+		read, time.Since(startTime), totals.created, totals.replayed, rejects.count)
+	if rejects.count > 0 {
+		fmt.Printf("Rejected rows written to %s\n", *rejectPath)
+	}
+
+	// Imported rows are usually backdated further than the rollup worker's
+	// poll fallback looks, and without the worker nothing counts them, so
+	// their days are rebuilt here
+	if totals.created > 0 {
+		first, last := totals.firstDay.Format("2006-01-02"), totals.lastDay.Format("2006-01-02")
+		fmt.Printf("Rebuilding daily stats from %s to %s...\n", first, last)
+		if err := store.RebuildDailyStats(context.Background(), totals.firstDay, totals.lastDay.AddDate(0, 0, 1)); err != nil {
+			log.Fatalf("Failed to rebuild daily stats: %v\nRun go run ./cmd/rollup -from %s -to %s", err, first, last)
+		}
+		fmt.Println("Daily stats rebuilt")
+	}
+}
+
+// parseColumnMapping parses field=column pairs into a field to column map
+func parseColumnMapping(spec string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	if spec == "" {
+		return mapping, nil
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("expected field=column, got %q", pair)
+		}
+		mapping[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return mapping, nil
+}
+
+// prepare converts and validates a request the same way the ingestion API does
+func prepare(service *services.TransactionService, validate *validator.Validate, req models.TransactionRequest) (models.Transaction, error) {
+	if err := validate.Struct(req); err != nil {
+		return models.Transaction{}, errors.New(strings.Join(utils.ValidationMessages(err), "; "))
+	}
+	return service.BuildTransaction(context.Background(), req)
+}
+
+func readCSV(input io.Reader, service *services.TransactionService, mapping map[string]string, validate *validator.Validate, emit func(row), rejects *rejectWriter) (int, error) {
+	reader := csv.NewReader(bufio.NewReader(input))
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+	rejects.start(header)
+
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		positions[strings.TrimSpace(name)] = i
+	}
+
+	// Resolve every transaction field to its column index, -1 if absent
+	fields := []string{"userId", "roundId", "type", "amount", "currency", "usdAmount", "createdAt", "idempotencyKey"}
+	columnOf := make(map[string]int, len(fields))
+	for _, field := range fields {
+		column := field
+		if mapped, ok := mapping[field]; ok {
+			column = mapped
+		}
+		index, ok := positions[column]
+		if !ok {
+			index = -1
+		}
+		columnOf[field] = index
+	}
+
+	read := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return read, nil
+		}
+		read++
+
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return read, err
+			}
+			rejects.reject(rejection{line: parseErr.StartLine, reason: parseErr.Err.Error()})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		value := func(field string) string {
+			index := columnOf[field]
+			if index < 0 || index >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[index])
+		}
+
+		// Records are reused by the reader, so keep a copy for the reject file
+		record = append([]string(nil), record...)
+		req := models.TransactionRequest{
+			UserID:         value("userId"),
+			RoundID:        value("roundId"),
+			Type:           value("type"),
+			Amount:         json.Number(value("amount")),
+			Currency:       value("currency"),
+			USDAmount:      json.Number(value("usdAmount")),
+			IdempotencyKey: value("idempotencyKey"),
+		}
+		if createdAt := value("createdAt"); createdAt != "" {
+			parsed, err := parseTimestamp(createdAt)
+			if err != nil {
+				rejects.reject(rejection{line: line, reason: err.Error(), record: record})
+				continue
+			}
+			req.CreatedAt = &parsed
+		}
+
+		tx, err := prepare(service, validate, req)
+		if err != nil {
+			rejects.reject(rejection{line: line, reason: err.Error(), record: record})
+			continue
+		}
+		emit(row{line: line, record: record, tx: tx})
+	}
+}
+
+func readNDJSON(input io.Reader, service *services.TransactionService, validate *validator.Validate, emit func(row), rejects *rejectWriter) (int, error) {
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	rejects.start([]string{"record"})
+
+	read, line := 0, 0
+	for scanner.Scan() {
+		line++
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" {
+			continue
+		}
+		read++
+		record := []string{raw}
+
+		var req models.TransactionRequest
+		if err := json.Unmarshal([]byte(raw), &req); err != nil {
+			rejects.reject(rejection{line: line, reason: "malformed JSON: " + err.Error(), record: record})
+			continue
+		}
+
+		tx, err := prepare(service, validate, req)
+		if err != nil {
+			rejects.reject(rejection{line: line, reason: err.Error(), record: record})
+			continue
+		}
+		emit(row{line: line, record: record, tx: tx})
+	}
+
+	return read, scanner.Err()
+}
+
+// parseTimestamp accepts RFC 3339 timestamps and plain "2006-01-02 15:04:05" UTC times
+func parseTimestamp(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02 15:04:05", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("createdAt %q is not an RFC 3339 timestamp", value)
+}
+
+// writeBatch stores one batch, reporting rows the store refused
+func writeBatch(service *services.TransactionService, batch []row, rejects *rejectWriter, totals *counters) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	txs := make([]models.Transaction, len(batch))
+	for i, r := range batch {
+		txs[i] = r.tx
+	}
+
+	results, err := service.Create(ctx, txs)
+	if err != nil {
+		for _, r := range batch {
+			rejects.reject(rejection{line: r.line, reason: "bulk write failed: " + err.Error(), record: r.record})
+		}
+		return
+	}
+
+	totals.mu.Lock()
+	defer totals.mu.Unlock()
+	for i, result := range results {
+		switch result.Status {
+		case services.IngestCreated:
+			totals.created++
+			totals.addDay(txs[i].CreatedAt)
+		case services.IngestReplayed:
+			totals.replayed++
+		default:
+			rejects.reject(rejection{line: batch[i].line, reason: result.Err.Error(), record: batch[i].record})
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"admin_statistics_api/config"
+	"admin_statistics_api/services"
+
+	"github.com/joho/godotenv"
+)
+
+// Rebuilds the daily_stats rollup collection from raw transactions.
+//
+//	go run ./cmd/rollup                                   # every day
+//	go run ./cmd/rollup -from 2024-01-01 -to 2024-01-31   # inclusive UTC days
+func main() {
+	fromFlag := flag.String("from", "", "first day to rebuild (YYYY-MM-DD), defaults to the beginning of time")
+	toFlag := flag.String("to", "", "last day to rebuild (YYYY-MM-DD), defaults to today")
+	flag.Parse()
+
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		fmt.Println("No .env file found, using default values")
+	}
+
+	from := time.Unix(0, 0).UTC()
+	if *fromFlag != "" {
+		parsed, err := time.Parse("2006-01-02", *fromFlag)
+		if err != nil {
+			log.Fatal("Invalid -from date:", err)
+		}
+		from = parsed
+	}
+
+	to := time.Now().UTC()
+	if *toFlag != "" {
+		parsed, err := time.Parse("2006-01-02", *toFlag)
+		if err != nil {
+			log.Fatal("Invalid -to date:", err)
+		}
+		to = parsed
+	}
+	// Rollups are rebuilt for [from, to), so include the whole last day
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+
+	config.ConnectDatabase()
+	defer config.DisconnectDatabase()
+
+	fmt.Printf("Rebuilding daily stats from %s to %s...\n", from.Format("2006-01-02"), to.AddDate(0, 0, -1).Format("2006-01-02"))
+	startTime := time.Now()
+
+	store := services.NewMongoTransactionStore(config.DB)
+	if err := store.RebuildDailyStats(context.Background(), from, to); err != nil {
+		log.Fatal("Failed to rebuild daily stats:", err)
+	}
+
+	fmt.Printf("Daily stats rebuilt in %v\n", time.Since(startTime))
+}
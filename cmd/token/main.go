@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/joho/godotenv"
+)
+
+// Issues an HS256 bearer token signed with JWT_SECRET, for development
+// and scripts.
+//
+//	go run ./cmd/token -role analyst
+//	go run ./cmd/token -sub alice -role admin -ttl 1h
+func main() {
+	subject := flag.String("sub", "dev", "subject of the token")
+	roles := flag.String("role", "viewer", "comma separated roles: viewer, analyst, admin")
+	ttl := flag.Duration("ttl", 12*time.Hour, "how long the token is valid")
+	flag.Parse()
+
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		fmt.Fprintln(os.Stderr, "No .env file found, using default values")
+	}
+
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		log.Fatal("JWT_SECRET is not set")
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":   *subject,
+		"roles": strings.Split(*roles, ","),
+		"iat":   now.Unix(),
+		"exp":   now.Add(*ttl).Unix(),
+	}
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		claims["iss"] = issuer
+	}
+	if audience := os.Getenv("JWT_AUDIENCE"); audience != "" {
+		claims["aud"] = audience
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		log.Fatal("Failed to sign token:", err)
+	}
+	fmt.Println(token)
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"admin_statistics_api/middleware"
+)
+
+// NewAuthenticator configures bearer token verification: HS256 tokens
+// signed with JWT_SECRET and RS256 tokens signed by a key in the JWKS file
+// named by JWT_JWKS_FILE. At least one of them must be set.
+func NewAuthenticator() *middleware.Authenticator {
+	config := middleware.AuthConfig{
+		HMACSecret: []byte(os.Getenv("JWT_SECRET")),
+		Issuer:     os.Getenv("JWT_ISSUER"),
+		Audience:   os.Getenv("JWT_AUDIENCE"),
+		RolesClaim: os.Getenv("JWT_ROLES_CLAIM"),
+	}
+
+	if path := os.Getenv("JWT_JWKS_FILE"); path != "" {
+		keys, err := middleware.LoadJWKS(path)
+		if err != nil {
+			log.Fatal("Failed to load JWT_JWKS_FILE:", err)
+		}
+		config.RSAKeys = keys
+		log.Printf("Verifying RS256 tokens with %d keys from %s", len(keys), path)
+	}
+
+	// JWT_ROLE_MAPPING maps claim values to roles, e.g.
+	// "stats-readers=viewer,finance=analyst,platform-ops=admin"
+	if mapping := os.Getenv("JWT_ROLE_MAPPING"); mapping != "" {
+		config.RoleMapping = make(map[string]middleware.Role)
+		for _, pair := range strings.Split(mapping, ",") {
+			value, role, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok || value == "" {
+				log.Fatalf("Invalid JWT_ROLE_MAPPING entry %q, expected value=role", pair)
+			}
+			config.RoleMapping[value] = middleware.Role(role)
+		}
+	}
+
+	auth, err := middleware.NewAuthenticator(config)
+	if err != nil {
+		log.Fatal("Failed to configure authentication:", err)
+	}
+	return auth
+}
+
+// APIKeyCacheTTL is how long verified API keys are cached in process, from
+// API_KEY_CACHE_TTL and 30 seconds by default. A key rotated or revoked on
+// another instance keeps working there for at most this long.
+func APIKeyCacheTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("API_KEY_CACHE_TTL")); err == nil && ttl >= 0 {
+		return ttl
+	}
+	return 30 * time.Second
+}
@@ -19,7 +19,7 @@ func ConnectRedis() {
 	}
 
 	redisPassword := os.Getenv("REDIS_PASSWORD")
-	
+
 	redisDB := 0
 	if dbStr := os.Getenv("REDIS_DB"); dbStr != "" {
 		if db, err := strconv.Atoi(dbStr); err == nil {
@@ -58,7 +58,7 @@ func SetCache(key string, value interface{}, expiration time.Duration) error {
 	if RedisClient == nil {
 		return fmt.Errorf("redis client not available")
 	}
-	
+
 	ctx := context.Background()
 	return RedisClient.Set(ctx, key, value, expiration).Err()
 }
@@ -67,7 +67,7 @@ func GetCache(key string) (string, error) {
 	if RedisClient == nil {
 		return "", fmt.Errorf("redis client not available")
 	}
-	
+
 	ctx := context.Background()
 	return RedisClient.Get(ctx, key).Result()
 }
@@ -76,7 +76,51 @@ func DeleteCache(key string) error {
 	if RedisClient == nil {
 		return fmt.Errorf("redis client not available")
 	}
-	
+
 	ctx := context.Background()
 	return RedisClient.Del(ctx, key).Err()
-}
\ No newline at end of file
+}
+
+// RedisCache wraps a redis client for injection into services. A nil client
+// behaves like an unavailable cache, matching the package-level helpers.
+type RedisCache struct {
+	client *redis.Client
+}
+
+func NewRedisCache(client *redis.Client) *RedisCache {
+	return &RedisCache{client: client}
+}
+
+func (r *RedisCache) Get(ctx context.Context, key string) (string, error) {
+	if r.client == nil {
+		return "", fmt.Errorf("redis client not available")
+	}
+	return r.client.Get(ctx, key).Result()
+}
+
+func (r *RedisCache) Set(ctx context.Context, key string, value string, expiration time.Duration) error {
+	if r.client == nil {
+		return fmt.Errorf("redis client not available")
+	}
+	return r.client.Set(ctx, key, value, expiration).Err()
+}
+
+func (r *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	if r.client == nil {
+		return fmt.Errorf("redis client not available")
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
+
+func (r *RedisCache) Scan(ctx context.Context, pattern string) ([]string, error) {
+	if r.client == nil {
+		return nil, fmt.Errorf("redis client not available")
+	}
+
+	var keys []string
+	iter := r.client.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"admin_statistics_api/services"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// LoadCurrencies loads the currency registry from the currencies
+// collection of db, seeding it with the default currencies when empty
+func LoadCurrencies(db *mongo.Database) *services.CurrencyRegistry {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	source := services.NewMongoCurrencySource(db)
+	seeded, err := source.SeedIfEmpty(ctx, services.DefaultCurrencies)
+	if err != nil {
+		log.Fatal("Failed to seed currencies:", err)
+	}
+	if seeded {
+		log.Printf("Seeded the currencies collection with %d default currencies", len(services.DefaultCurrencies))
+	}
+
+	registry := services.NewCurrencyRegistry(source)
+	if err := registry.Load(ctx); err != nil {
+		log.Fatal("Failed to load currencies:", err)
+	}
+	return registry
+}
+
+// CurrencyRefreshInterval is how often the currency registry is reloaded,
+// from CURRENCY_REFRESH_INTERVAL and one minute by default
+func CurrencyRefreshInterval() time.Duration {
+	if interval, err := time.ParseDuration(os.Getenv("CURRENCY_REFRESH_INTERVAL")); err == nil && interval > 0 {
+		return interval
+	}
+	return time.Minute
+}
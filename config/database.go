@@ -7,6 +7,7 @@ import (
 	"os"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -47,6 +48,20 @@ func ConnectDatabase() {
 	
 	// Create indexes for better performance
 	createIndexes()
+	enablePreImages()
+}
+
+// enablePreImages has the server record transactions as they were before
+// a change, so the rollup worker can tell which day a deleted transaction
+// belonged to
+func enablePreImages() {
+	err := DB.RunCommand(context.Background(), bson.D{
+		{Key: "collMod", Value: "transactions"},
+		{Key: "changeStreamPreAndPostImages", Value: bson.M{"enabled": true}},
+	}).Err()
+	if err != nil {
+		log.Printf("Failed to enable change stream pre-images on transactions: %v", err)
+	}
 }
 
 func createIndexes() {
@@ -61,6 +76,15 @@ func createIndexes() {
 		log.Printf("Failed to create createdAt index: %v", err)
 	}
 
+	// Compound index on createdAt and _id for the resumable transaction
+	// export, which pages in that order
+	_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}},
+	})
+	if err != nil {
+		log.Printf("Failed to create createdAt/_id index: %v", err)
+	}
+
 	// Index on userId for user-specific queries
 	_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{
 		Keys: map[string]int{"userId": 1},
@@ -93,6 +117,110 @@ func createIndexes() {
 		log.Printf("Failed to create type index: %v", err)
 	}
 
+	// Unique client-supplied idempotency keys so retried submissions are
+	// stored only once
+	_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "idempotencyKey", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"idempotencyKey": bson.M{"$type": "string"}}),
+	})
+	if err != nil {
+		log.Printf("Failed to create idempotencyKey index: %v", err)
+	}
+
+	// At most one Wager and one Payout per round, unless extra payouts are
+	// explicitly allowed and given their own roundSlot
+	_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "roundId", Value: 1},
+			{Key: "type", Value: 1},
+			{Key: "roundSlot", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Failed to create round uniqueness index: %v", err)
+	}
+
+	// One rollup per day, currency and type. Rebuilds merge on this key and
+	// it also serves the day range lookups.
+	_, err = DB.Collection("daily_stats").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "day", Value: 1},
+			{Key: "currency", Value: 1},
+			{Key: "type", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Failed to create daily_stats index: %v", err)
+	}
+
+	// The users counted in each rollup, so incremental updates only count
+	// users new to the day
+	_, err = DB.Collection("daily_stat_users").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "day", Value: 1},
+			{Key: "currency", Value: 1},
+			{Key: "type", Value: 1},
+			{Key: "userId", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Failed to create daily_stat_users index: %v", err)
+	}
+
+	// The transactions counted in the rollups, keyed by their own _id; the
+	// day serves the removal of rows a rebuild no longer covers
+	_, err = DB.Collection("daily_stat_transactions").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "day", Value: 1},
+			{Key: "rebuildId", Value: 1},
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to create daily_stat_transactions index: %v", err)
+	}
+
+	// One rate per pair and instant; also serves the latest-rate-before
+	// lookups used for conversion
+	_, err = DB.Collection("exchange_rates").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "base", Value: 1},
+			{Key: "quote", Value: 1},
+			{Key: "effectiveAt", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Failed to create exchange_rates index: %v", err)
+	}
+
+	// One registry entry per currency code
+	_, err = DB.Collection("currencies").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "code", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Failed to create currencies index: %v", err)
+	}
+
+	// API keys are looked up by the hash of their secret and listed by owner
+	_, err = DB.Collection("api_keys").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "owner", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to create api_keys indexes: %v", err)
+	}
+
 	fmt.Println("Database indexes created successfully!")
 }
 
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+
+	"admin_statistics_api/middleware"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// rateLimitPrefix prefixes the Redis keys holding token buckets
+const rateLimitPrefix = "ratelimit:"
+
+// tokenBucketScript refills and spends a bucket atomically, with the Redis
+// clock so every instance sees the same time. A cost of 0 only checks for
+// a point left. It returns whether the cost was spent and the tokens left,
+// as a string since Redis truncates Lua numbers to integers.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) + tonumber(time[2]) / 1000000
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'at')
+local tokens = tonumber(state[1]) or capacity
+local at = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - at) * rate)
+
+local allowed = 0
+if tokens >= math.max(cost, 1) then
+	tokens = tokens - cost
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'at', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate * 1000))
+return {allowed, tostring(tokens)}
+`)
+
+// RedisRateLimiter keeps token buckets in Redis so limits are shared by
+// every instance. A nil client, or a failing one, falls back to buckets in
+// process, matching how the cache tolerates a missing Redis.
+type RedisRateLimiter struct {
+	client   *redis.Client
+	policy   middleware.RateLimitPolicy
+	fallback *middleware.MemoryRateLimiter
+}
+
+func NewRedisRateLimiter(client *redis.Client, policy middleware.RateLimitPolicy) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		client:   client,
+		policy:   policy,
+		fallback: middleware.NewMemoryRateLimiter(policy),
+	}
+}
+
+func (r *RedisRateLimiter) Allow(ctx context.Context, key string, cost int) (middleware.RateLimitResult, error) {
+	if r.client == nil {
+		return r.fallback.Allow(ctx, key, cost)
+	}
+
+	cost = r.policy.ClampCost(cost)
+	values, err := tokenBucketScript.Run(ctx, r.client, []string{rateLimitPrefix + key},
+		r.policy.Capacity, r.policy.RefillPerSecond, cost).Slice()
+	if err == nil && len(values) != 2 {
+		err = redis.Nil
+	}
+	var tokens float64
+	if err == nil {
+		text, _ := values[1].(string)
+		tokens, err = strconv.ParseFloat(text, 64)
+	}
+	if err != nil {
+		log.Printf("Redis rate limiter failed, using in-process buckets: %v", err)
+		return r.fallback.Allow(ctx, key, cost)
+	}
+
+	allowed, _ := values[0].(int64)
+	return r.policy.Result(tokens, cost, allowed == 1), nil
+}
+
+// NewRateLimiter builds the rate limiter from RATE_LIMIT_CAPACITY, the
+// points a client may spend in a burst (120 by default), and
+// RATE_LIMIT_REFILL, the points regained per second (2 by default). A
+// capacity of 0 disables rate limiting and returns nil.
+func NewRateLimiter(client *redis.Client) middleware.RateLimiter {
+	policy := middleware.RateLimitPolicy{Capacity: 120, RefillPerSecond: 2}
+	if value := os.Getenv("RATE_LIMIT_CAPACITY"); value != "" {
+		capacity, err := strconv.Atoi(value)
+		if err != nil || capacity < 0 {
+			log.Fatalf("Invalid RATE_LIMIT_CAPACITY %q", value)
+		}
+		policy.Capacity = capacity
+	}
+	if value := os.Getenv("RATE_LIMIT_REFILL"); value != "" {
+		refill, err := strconv.ParseFloat(value, 64)
+		if err != nil || refill <= 0 {
+			log.Fatalf("Invalid RATE_LIMIT_REFILL %q", value)
+		}
+		policy.RefillPerSecond = refill
+	}
+
+	if policy.Capacity == 0 {
+		log.Println("Rate limiting disabled")
+		return nil
+	}
+	if client == nil {
+		log.Println("Rate limiting with in-process buckets, Redis is not available")
+	}
+	return NewRedisRateLimiter(client, policy)
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"log"
+	"os"
+
+	"admin_statistics_api/services"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// NewRateProvider returns the source of exchange rates: the CSV or JSON
+// file named by EXCHANGE_RATES_FILE when set, and otherwise the
+// exchange_rates collection of db
+func NewRateProvider(db *mongo.Database) services.RateProvider {
+	path := os.Getenv("EXCHANGE_RATES_FILE")
+	if path == "" {
+		return services.NewMongoRateProvider(db)
+	}
+
+	provider, err := services.NewFileRateProvider(path)
+	if err != nil {
+		log.Fatal("Failed to load EXCHANGE_RATES_FILE:", err)
+	}
+	log.Printf("Serving exchange rates from %s", path)
+	return provider
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"admin_statistics_api/utils"
+)
+
+// DefaultTimezone returns the time zone used for date ranges and buckets
+// when a request does not pass tz, taken from DEFAULT_TIMEZONE (UTC if unset)
+func DefaultTimezone() *time.Location {
+	name := os.Getenv("DEFAULT_TIMEZONE")
+	if name == "" {
+		return time.UTC
+	}
+
+	loc, err := utils.LoadTimezone(name)
+	if err != nil {
+		log.Fatal("Invalid DEFAULT_TIMEZONE:", err)
+	}
+	return loc
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"admin_statistics_api/models"
+	"admin_statistics_api/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type APIKeyHandler struct {
+	service   *services.APIKeyService
+	validator *validator.Validate
+}
+
+func NewAPIKeyHandler(service *services.APIKeyService) *APIKeyHandler {
+	validate := validator.New()
+	validate.RegisterValidation("scope", func(fl validator.FieldLevel) bool {
+		return models.IsValidScope(fl.Field().String())
+	})
+
+	return &APIKeyHandler{
+		service:   service,
+		validator: validate,
+	}
+}
+
+// CreateKey handles POST /admin/api_keys. The secret is only returned in
+// this response.
+func (h *APIKeyHandler) CreateKey(c *gin.Context) {
+	var req models.APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid API key request",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid API key request",
+			"details": err.Error(),
+		})
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid API key request",
+			"details": "expiresAt must be in the future",
+		})
+		return
+	}
+
+	created, err := h.service.CreateKey(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to create API key",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    created,
+	})
+}
+
+// ListKeys handles GET /admin/api_keys. owner limits the list to one owner
+// and include_revoked=true adds revoked keys.
+func (h *APIKeyHandler) ListKeys(c *gin.Context) {
+	var includeRevoked bool
+	if value := c.Query("include_revoked"); value != "" {
+		var err error
+		includeRevoked, err = strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid include_revoked parameter",
+				"details": "include_revoked must be true or false",
+			})
+			return
+		}
+	}
+
+	keys, err := h.service.ListKeys(c.Request.Context(), c.Query("owner"), includeRevoked)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list API keys",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    gin.H{"keys": keys},
+	})
+}
+
+// RotateKey handles POST /admin/api_keys/:id/rotate. The new secret is only
+// returned in this response and the old one stops working.
+func (h *APIKeyHandler) RotateKey(c *gin.Context) {
+	id, ok := keyID(c)
+	if !ok {
+		return
+	}
+
+	rotated, err := h.service.RotateKey(c.Request.Context(), id)
+	if keyError(c, err, "Failed to rotate API key") {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    rotated,
+	})
+}
+
+// RevokeKey handles DELETE /admin/api_keys/:id
+func (h *APIKeyHandler) RevokeKey(c *gin.Context) {
+	id, ok := keyID(c)
+	if !ok {
+		return
+	}
+
+	key, err := h.service.RevokeKey(c.Request.Context(), id)
+	if keyError(c, err, "Failed to revoke API key") {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    key,
+	})
+}
+
+// keyID parses the :id path parameter, answering 400 when it is invalid
+func keyID(c *gin.Context) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid API key ID",
+			"details": "id must be a valid MongoDB ObjectID",
+		})
+		return primitive.ObjectID{}, false
+	}
+	return id, true
+}
+
+// keyError answers a failed change to a key and reports whether it did
+func keyError(c *gin.Context, err error, message string) bool {
+	if errors.Is(err, services.ErrAPIKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "API key not found",
+			"details": "No API key exists with ID " + c.Param("id"),
+		})
+		return true
+	}
+	if errors.Is(err, services.ErrAPIKeyRevoked) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "API key has been revoked",
+			"details": err.Error(),
+		})
+		return true
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		})
+		return true
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"admin_statistics_api/models"
+	"admin_statistics_api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CurrencyHandler struct {
+	currencies *services.CurrencyRegistry
+}
+
+func NewCurrencyHandler(currencies *services.CurrencyRegistry) *CurrencyHandler {
+	return &CurrencyHandler{
+		currencies: currencies,
+	}
+}
+
+// ListCurrencies handles GET /currencies. type limits the list to crypto or
+// fiat currencies and enabled=true to those accepted for new transactions.
+func (h *CurrencyHandler) ListCurrencies(c *gin.Context) {
+	currencyType := c.Query("type")
+	if currencyType != "" && currencyType != models.CurrencyTypeCrypto && currencyType != models.CurrencyTypeFiat {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid type parameter",
+			"details": "type must be one of [crypto fiat]",
+		})
+		return
+	}
+
+	var enabledOnly bool
+	if enabled := c.Query("enabled"); enabled != "" {
+		var err error
+		enabledOnly, err = strconv.ParseBool(enabled)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid enabled parameter",
+				"details": "enabled must be true or false",
+			})
+			return
+		}
+	}
+
+	currencies := []models.Currency{}
+	for _, currency := range h.currencies.List(currencyType) {
+		if enabledOnly && !currency.Enabled {
+			continue
+		}
+		currencies = append(currencies, currency)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"currencies":  currencies,
+			"refreshedAt": h.currencies.RefreshedAt(),
+		},
+	})
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"admin_statistics_api/models"
+	"admin_statistics_api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRateUpload caps the number of rates accepted by one upload
+const maxRateUpload = 10000
+
+type ExchangeRateHandler struct {
+	rates    services.RateProvider
+	location *time.Location
+}
+
+// NewExchangeRateHandler creates a handler that reads listing ranges in
+// location unless a request passes tz
+func NewExchangeRateHandler(rates services.RateProvider, location *time.Location) *ExchangeRateHandler {
+	return &ExchangeRateHandler{
+		rates:    rates,
+		location: location,
+	}
+}
+
+// ListSeries handles GET /admin/exchange_rates
+func (h *ExchangeRateHandler) ListSeries(c *gin.Context) {
+	series, err := h.rates.Series(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list exchange rate series",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    gin.H{"series": series},
+	})
+}
+
+// ListRates handles GET /admin/exchange_rates/:base/:quote. Without from,
+// to or period the whole series is returned.
+func (h *ExchangeRateHandler) ListRates(c *gin.Context) {
+	base := strings.ToUpper(c.Param("base"))
+	quote := strings.ToUpper(c.Param("quote"))
+
+	data := gin.H{"base": base, "quote": quote}
+	var from, to time.Time
+	if c.Query("from") != "" || c.Query("to") != "" || c.Query("period") != "" {
+		timeRange, err := parseTimeRange(c, h.location)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid date parameters",
+				"details": err.Error(),
+			})
+			return
+		}
+		from, to = timeRange.From, timeRange.To
+		data = timeRange.data(data)
+	}
+
+	rates, err := h.rates.Rates(c.Request.Context(), base, quote, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list exchange rates",
+			"details": err.Error(),
+		})
+		return
+	}
+	data["rates"] = rates
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// UploadRates handles POST /admin/exchange_rates. The body is a JSON array
+// of rates or, with a text/csv content type, CSV with a
+// base,quote,rate,effectiveAt header. Rates replace stored ones of the
+// same pair and EffectiveAt.
+func (h *ExchangeRateHandler) UploadRates(c *gin.Context) {
+	format := services.RateFormatJSON
+	if c.ContentType() == "text/csv" {
+		format = services.RateFormatCSV
+	}
+
+	rates, err := services.ParseRates(c.Request.Body, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid exchange rates",
+			"details": err.Error(),
+		})
+		return
+	}
+	if len(rates) == 0 || len(rates) > maxRateUpload {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid exchange rates",
+			"details": fmt.Sprintf("an upload must contain between 1 and %d rates", maxRateUpload),
+		})
+		return
+	}
+
+	inserted, updated, err := h.rates.SaveRates(c.Request.Context(), rates)
+	if errors.Is(err, services.ErrReadOnlyRates) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Exchange rates are read-only",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to store exchange rates",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"inserted": inserted,
+			"updated":  updated,
+			"series":   uploadedSeries(rates),
+		},
+	})
+}
+
+// uploadedSeries lists the distinct pairs of an upload as BASE/QUOTE
+func uploadedSeries(rates []models.ExchangeRate) []string {
+	seen := make(map[string]bool)
+	pairs := []string{}
+	for _, rate := range rates {
+		pair := rate.Base + "/" + rate.Quote
+		if !seen[pair] {
+			seen[pair] = true
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs
+}
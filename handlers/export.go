@@ -0,0 +1,485 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"admin_statistics_api/models"
+	"admin_statistics_api/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parquet-go/parquet-go"
+	"github.com/xuri/excelize/v2"
+)
+
+// Response formats of the statistics endpoints
+const (
+	formatJSON    = "json"
+	formatCSV     = "csv"
+	formatXLSX    = "xlsx"
+	formatParquet = "parquet"
+)
+
+const (
+	mimeXLSX    = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	mimeParquet = "application/vnd.apache.parquet"
+)
+
+// formatMIMETypes lists the media types offered for each format, JSON first
+// so that it wins when the client accepts anything
+var formatMIMETypes = []struct {
+	format string
+	mime   string
+}{
+	{formatJSON, gin.MIMEJSON},
+	{formatCSV, "text/csv"},
+	{formatXLSX, mimeXLSX},
+	{formatParquet, mimeParquet},
+	{formatParquet, "application/x-parquet"},
+}
+
+// Kinds of export columns
+const (
+	columnString = iota
+	columnFloat
+	columnDecimal
+	columnInt
+)
+
+type exportColumn struct {
+	name string
+	kind int
+}
+
+// exportTable is a statistics result flattened into rows. Values are
+// strings, float64s, presented models.Decimals or ints matching the kind
+// of their column.
+type exportTable struct {
+	name    string
+	columns []exportColumn
+	rows    [][]interface{}
+}
+
+// responseFormat picks the response format from the format query parameter
+// or, when it is absent, from the Accept header
+func responseFormat(c *gin.Context) (string, error) {
+	if format := c.Query("format"); format != "" {
+		for _, offered := range formatMIMETypes {
+			if offered.format == format {
+				return format, nil
+			}
+		}
+		return "", fmt.Errorf("format must be one of [json csv xlsx parquet], got %q", format)
+	}
+
+	offered := make([]string, len(formatMIMETypes))
+	for i, f := range formatMIMETypes {
+		offered[i] = f.mime
+	}
+	negotiated := c.NegotiateFormat(offered...)
+	for _, f := range formatMIMETypes {
+		if f.mime == negotiated {
+			return f.format, nil
+		}
+	}
+	return formatJSON, nil
+}
+
+// render writes a statistics response in format, as resolved by
+// responseFormat before any query ran: JSON or a CSV, XLSX or Parquet
+// download of table. Tabular formats only carry the main result, not
+// comparisons.
+func render(c *gin.Context, format string, timeRange requestRange, data gin.H, table exportTable) {
+	if format == formatJSON {
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    timeRange.data(data),
+		})
+		return
+	}
+
+	var body []byte
+	var err error
+	var contentType string
+	switch format {
+	case formatCSV:
+		body, err = table.csv()
+		contentType = "text/csv; charset=utf-8"
+	case formatXLSX:
+		body, err = table.xlsx()
+		contentType = mimeXLSX
+	case formatParquet:
+		body, err = table.parquet()
+		contentType = mimeParquet
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to export results",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("%s_%s.%s", table.name, exportRangeName(timeRange), format)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// exportRangeName describes a range for file names: plain dates when it
+// covers whole days in its time zone and timestamps otherwise
+func exportRangeName(r requestRange) string {
+	from := r.From.In(r.Location)
+	last := r.To.In(r.Location)
+	next := r.To.Add(time.Nanosecond).In(r.Location)
+	isMidnight := func(t time.Time) bool {
+		return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0
+	}
+	if isMidnight(from) && isMidnight(next) {
+		return from.Format("2006-01-02") + "_" + last.Format("2006-01-02")
+	}
+	return from.Format("20060102T150405") + "_" + r.End.In(r.Location).Format("20060102T150405")
+}
+
+// formatDecimal prints a float in plain decimal notation, never with an
+// exponent, using as few digits as represent it exactly
+func formatDecimal(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func (t exportTable) csv() ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	header := make([]string, len(t.columns))
+	for i, column := range t.columns {
+		header[i] = column.name
+	}
+	writer.Write(header)
+
+	record := make([]string, len(t.columns))
+	for _, row := range t.rows {
+		for i, value := range row {
+			switch v := value.(type) {
+			case float64:
+				record[i] = formatDecimal(v)
+			case models.Decimal:
+				record[i] = v.Text()
+			case int:
+				record[i] = strconv.Itoa(v)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		writer.Write(record)
+	}
+
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+func (t exportTable) xlsx() ([]byte, error) {
+	file := excelize.NewFile()
+	defer file.Close()
+
+	sheet := t.name
+	if len(sheet) > 31 {
+		sheet = sheet[:31]
+	}
+	if err := file.SetSheetName("Sheet1", sheet); err != nil {
+		return nil, err
+	}
+
+	// Show up to ten decimals without switching to scientific notation
+	decimalFormat := "#,##0.00########"
+	decimalStyle, err := file.NewStyle(&excelize.Style{CustomNumFmt: &decimalFormat})
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := file.NewStreamWriter(sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]interface{}, len(t.columns))
+	for i, column := range t.columns {
+		header[i] = column.name
+	}
+	if err := stream.SetRow("A1", header); err != nil {
+		return nil, err
+	}
+
+	for r, row := range t.rows {
+		cells := make([]interface{}, len(row))
+		for i, value := range row {
+			switch v := value.(type) {
+			case float64:
+				cells[i] = excelize.Cell{StyleID: decimalStyle, Value: v}
+			case models.Decimal:
+				// Spreadsheet numbers are doubles, so amounts are written as
+				// text to keep every digit, as in CSV
+				cells[i] = v.Text()
+			default:
+				cells[i] = value
+			}
+		}
+		cell, err := excelize.CoordinatesToCellName(1, r+2)
+		if err != nil {
+			return nil, err
+		}
+		if err := stream.SetRow(cell, cells); err != nil {
+			return nil, err
+		}
+	}
+	if err := stream.Flush(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := file.Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// parquet writes the table through a struct type built from the columns,
+// which keeps the column order of the other formats. Decimal columns are
+// strings holding the exact amount, since their scale differs between
+// currencies and a double would round it.
+func (t exportTable) parquet() ([]byte, error) {
+	fields := make([]reflect.StructField, len(t.columns))
+	for i, column := range t.columns {
+		var fieldType reflect.Type
+		switch column.kind {
+		case columnFloat:
+			fieldType = reflect.TypeOf(float64(0))
+		case columnInt:
+			fieldType = reflect.TypeOf(int64(0))
+		default:
+			fieldType = reflect.TypeOf("")
+		}
+		fields[i] = reflect.StructField{
+			Name: fmt.Sprintf("Column%d", i),
+			Type: fieldType,
+			Tag:  reflect.StructTag(fmt.Sprintf(`parquet:"%s"`, column.name)),
+		}
+	}
+	rowType := reflect.StructOf(fields)
+
+	var buf bytes.Buffer
+	writer := parquet.NewWriter(&buf, parquet.SchemaOf(reflect.New(rowType).Interface()))
+	for _, row := range t.rows {
+		record := reflect.New(rowType).Elem()
+		for i, value := range row {
+			switch v := value.(type) {
+			case int:
+				record.Field(i).SetInt(int64(v))
+			case models.Decimal:
+				record.Field(i).SetString(v.Text())
+			default:
+				record.Field(i).Set(reflect.ValueOf(v))
+			}
+		}
+		if err := writer.Write(record.Interface()); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// addReportValue appends the value in the reporting currency to every row
+// when results were reported in a currency other than USD
+func (t *exportTable) addReportValue(values []models.Money) {
+	rows := make([][]models.Money, len(values))
+	for i, value := range values {
+		rows[i] = []models.Money{value}
+	}
+	t.addReportColumns([]string{"report_value"}, rows)
+}
+
+// addReportColumns appends a row's values in the reporting currency as the
+// named columns, followed by the currency, when results were reported in a
+// currency other than USD
+func (t *exportTable) addReportColumns(names []string, rows [][]models.Money) {
+	if len(rows) == 0 || rows[0][0].Currency == services.QuoteUSD {
+		return
+	}
+	for _, name := range names {
+		t.columns = append(t.columns, exportColumn{name, columnDecimal})
+	}
+	t.columns = append(t.columns, exportColumn{"report_currency", columnString})
+	for i, values := range rows {
+		for _, value := range values {
+			t.rows[i] = append(t.rows[i], value.Value)
+		}
+		t.rows[i] = append(t.rows[i], values[0].Currency)
+	}
+}
+
+// addRevaluation appends the rate, revalued USD value and FX gain or loss
+// to every row when the results were revalued
+func (t *exportTable) addRevaluation(revaluations []*models.Revaluation) {
+	if len(revaluations) == 0 || revaluations[0] == nil {
+		return
+	}
+	t.columns = append(t.columns,
+		exportColumn{"revaluation_rate", columnFloat},
+		exportColumn{"revalued_usd", columnDecimal},
+		exportColumn{"fx_gain_loss", columnDecimal},
+	)
+	for i, revaluation := range revaluations {
+		t.rows[i] = append(t.rows[i], revaluation.Rate, revaluation.RevaluedUSD, revaluation.FXGainLoss)
+	}
+}
+
+func ggrTable(results []models.GrossGamingRevenue) exportTable {
+	table := exportTable{
+		name: "gross_gaming_revenue",
+		columns: []exportColumn{
+			{"currency", columnString},
+			{"amount", columnDecimal},
+			{"usd_value", columnDecimal},
+		},
+	}
+	var values []models.Money
+	var revaluations []*models.Revaluation
+	for _, ggr := range results {
+		table.rows = append(table.rows, []interface{}{ggr.Currency, ggr.Amount, ggr.USDValue})
+		values = append(values, ggr.Value)
+		revaluations = append(revaluations, ggr.Revaluation)
+	}
+	table.addReportValue(values)
+	table.addRevaluation(revaluations)
+	return table
+}
+
+func dailyWagerVolumeTable(results []models.DailyWagerVolume) exportTable {
+	table := exportTable{
+		name: "daily_wager_volume",
+		columns: []exportColumn{
+			{"date", columnString},
+			{"currency", columnString},
+			{"amount", columnDecimal},
+			{"usd_value", columnDecimal},
+		},
+	}
+	var values []models.Money
+	var revaluations []*models.Revaluation
+	for _, volume := range results {
+		table.rows = append(table.rows, []interface{}{volume.Date, volume.Currency, volume.Amount, volume.USDValue})
+		values = append(values, volume.Value)
+		revaluations = append(revaluations, volume.Revaluation)
+	}
+	table.addReportValue(values)
+	table.addRevaluation(revaluations)
+	return table
+}
+
+func wagerVolumeTable(results []models.WagerVolume, granularity string, loc *time.Location) exportTable {
+	table := exportTable{
+		name: "wager_volume_" + granularity,
+		columns: []exportColumn{
+			{"bucket", columnString},
+			{"currency", columnString},
+			{"amount", columnDecimal},
+			{"usd_value", columnDecimal},
+		},
+	}
+	var values []models.Money
+	var revaluations []*models.Revaluation
+	for _, volume := range results {
+		bucket := volume.Bucket.In(loc).Format(time.RFC3339)
+		table.rows = append(table.rows, []interface{}{bucket, volume.Currency, volume.Amount, volume.USDValue})
+		values = append(values, volume.Value)
+		revaluations = append(revaluations, volume.Revaluation)
+	}
+	table.addReportValue(values)
+	table.addRevaluation(revaluations)
+	return table
+}
+
+func userPercentileTable(result *models.UserWagerPercentile) exportTable {
+	table := exportTable{
+		name: "user_percentile",
+		columns: []exportColumn{
+			{"user_id", columnString},
+			{"total_wagered", columnDecimal},
+			{"percentile", columnFloat},
+			{"rank", columnInt},
+			{"total_users", columnInt},
+			{"tied_users", columnInt},
+		},
+		rows: [][]interface{}{{
+			result.UserID, result.TotalWagered, result.Percentile, result.Rank, result.TotalUsers, result.TiedUsers,
+		}},
+	}
+	table.addReportValue([]models.Money{result.Value})
+	return table
+}
+
+func userSummaryTable(result *models.UserSummary, loc *time.Location) exportTable {
+	table := exportTable{
+		name: "user_summary",
+		columns: []exportColumn{
+			{"user_id", columnString},
+			{"currency", columnString},
+			{"wagered", columnDecimal},
+			{"wagered_usd", columnDecimal},
+			{"paid_out", columnDecimal},
+			{"paid_out_usd", columnDecimal},
+			{"net_ggr", columnDecimal},
+			{"net_ggr_usd", columnDecimal},
+			{"round_count", columnInt},
+			{"wager_count", columnInt},
+			{"average_bet", columnDecimal},
+			{"largest_win", columnDecimal},
+			{"largest_win_usd", columnDecimal},
+			{"first_activity", columnString},
+			{"last_activity", columnString},
+		},
+	}
+	var values [][]models.Money
+	for _, s := range result.ByCurrency {
+		table.rows = append(table.rows, []interface{}{
+			result.UserID, s.Currency, s.Wagered, s.WageredUSD, s.PaidOut, s.PaidOutUSD, s.NetGGR, s.NetGGRUSD,
+			s.RoundCount, s.WagerCount, s.AverageBet, s.LargestWin, s.LargestWinUSD,
+			s.FirstActivity.In(loc).Format(time.RFC3339Nano), s.LastActivity.In(loc).Format(time.RFC3339Nano),
+		})
+		values = append(values, []models.Money{s.WageredValue, s.PaidOutValue, s.NetGGRValue, s.LargestWinValue})
+	}
+	table.addReportColumns([]string{"wagered_report", "paid_out_report", "net_ggr_report", "largest_win_report"}, values)
+	return table
+}
+
+func leaderboardTable(result *models.Leaderboard) exportTable {
+	table := exportTable{
+		name: "leaderboard_" + result.Metric,
+		columns: []exportColumn{
+			{"rank", columnInt},
+			{"user_id", columnString},
+			{"value", columnDecimal},
+			{"wagered_usd", columnDecimal},
+			{"paid_out_usd", columnDecimal},
+			{"net_loss_usd", columnDecimal},
+			{"rounds", columnInt},
+			{"percentile", columnFloat},
+		},
+	}
+	var values [][]models.Money
+	for _, e := range result.Entries {
+		table.rows = append(table.rows, []interface{}{
+			e.Rank, e.UserID, e.Value, e.WageredUSD, e.PaidOutUSD, e.NetLossUSD, e.Rounds, e.Percentile,
+		})
+		values = append(values, []models.Money{e.WageredValue, e.PaidOutValue, e.NetLossValue})
+	}
+	table.addReportColumns([]string{"wagered_report", "paid_out_report", "net_loss_report"}, values)
+	return table
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestInvalidFormatRejectedBeforeQuerying(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// Without a service any query would panic, so a 400 shows the format
+	// was checked first
+	h := NewStatisticsHandler(nil, nil, time.UTC)
+	router := gin.New()
+	router.GET("/gross_gaming_rev", h.GetGrossGamingRevenue)
+	router.GET("/daily_wager_volume", h.GetDailyWagerVolume)
+	router.GET("/wager_volume", h.GetWagerVolume)
+	router.GET("/user/:user_id/wager_percentile", h.GetUserWagerPercentile)
+	router.GET("/user/:user_id/summary", h.GetUserSummary)
+	router.GET("/leaderboard", h.GetLeaderboard)
+
+	for _, path := range []string{
+		"/gross_gaming_rev",
+		"/daily_wager_volume",
+		"/wager_volume",
+		"/user/65f1c0a0e4b0a1b2c3d4e5f6/wager_percentile",
+		"/user/65f1c0a0e4b0a1b2c3d4e5f6/summary",
+		"/leaderboard",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path+"?from=2024-01-01&to=2024-01-31&format=pdf", nil))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Invalid format parameter") {
+			t.Errorf("%s: got %d %s, want 400 for the format", path, w.Code, w.Body.String())
+		}
+	}
+}
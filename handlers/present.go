@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"fmt"
+
+	"admin_statistics_api/models"
+	"admin_statistics_api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Amount formats accepted by the amount_format query parameter
+const (
+	amountFormatString = "string"
+	amountFormatFloat  = "float"
+)
+
+// presenter rounds exact amounts to the precision of their currency just
+// before a response is written. Amounts are encoded as strings unless the
+// client asked for JSON numbers.
+type presenter struct {
+	currencies *services.CurrencyRegistry
+	asFloat    bool
+}
+
+// amountPresenter reads the amount_format query parameter
+func amountPresenter(c *gin.Context, currencies *services.CurrencyRegistry) (presenter, error) {
+	switch format := c.Query("amount_format"); format {
+	case "", amountFormatString:
+		return presenter{currencies: currencies}, nil
+	case amountFormatFloat:
+		return presenter{currencies: currencies, asFloat: true}, nil
+	default:
+		return presenter{}, fmt.Errorf("amount_format must be one of [string float], got %q", format)
+	}
+}
+
+func (p presenter) amount(value *models.Decimal, currency string) {
+	*value = value.Present(p.currencies.Decimals(currency), p.asFloat)
+}
+
+func (p presenter) usd(value *models.Decimal) {
+	p.amount(value, services.QuoteUSD)
+}
+
+func (p presenter) money(value *models.Money) {
+	p.amount(&value.Value, value.Currency)
+}
+
+func (p presenter) revaluation(revaluation *models.Revaluation) {
+	if revaluation == nil {
+		return
+	}
+	p.usd(&revaluation.RevaluedUSD)
+	p.usd(&revaluation.FXGainLoss)
+}
+
+func (p presenter) delta(delta *models.ValueDelta, currency string) {
+	p.amount(&delta.Current, currency)
+	p.amount(&delta.Previous, currency)
+	p.amount(&delta.Change, currency)
+}
+
+func (p presenter) comparison(comparison *models.PeriodComparison) {
+	for i := range comparison.ByCurrency {
+		p.delta(&comparison.ByCurrency[i].Amount, comparison.ByCurrency[i].Currency)
+		p.delta(&comparison.ByCurrency[i].USDValue, services.QuoteUSD)
+		p.delta(&comparison.ByCurrency[i].Value, comparison.ReportCurrency)
+	}
+	p.delta(&comparison.TotalUSD, services.QuoteUSD)
+	p.delta(&comparison.Total, comparison.ReportCurrency)
+}
+
+func (p presenter) valuation(summary *models.ValuationSummary) {
+	p.usd(&summary.BookedUSD)
+	p.usd(&summary.RevaluedUSD)
+	p.usd(&summary.FXGainLoss)
+}
+
+func (p presenter) ggr(results []models.GrossGamingRevenue) {
+	for i := range results {
+		p.amount(&results[i].Amount, results[i].Currency)
+		p.usd(&results[i].USDValue)
+		p.money(&results[i].Value)
+		p.revaluation(results[i].Revaluation)
+	}
+}
+
+func (p presenter) dailyWagerVolume(results []models.DailyWagerVolume) {
+	for i := range results {
+		p.amount(&results[i].Amount, results[i].Currency)
+		p.usd(&results[i].USDValue)
+		p.money(&results[i].Value)
+		p.revaluation(results[i].Revaluation)
+	}
+}
+
+func (p presenter) wagerVolume(results []models.WagerVolume) {
+	for i := range results {
+		p.amount(&results[i].Amount, results[i].Currency)
+		p.usd(&results[i].USDValue)
+		p.money(&results[i].Value)
+		p.revaluation(results[i].Revaluation)
+	}
+}
+
+func (p presenter) userPercentile(result *models.UserWagerPercentile) {
+	p.usd(&result.TotalWagered)
+	p.money(&result.Value)
+}
+
+func (p presenter) userSummary(result *models.UserSummary) {
+	p.usd(&result.TotalWageredUSD)
+	p.usd(&result.TotalPaidOutUSD)
+	p.usd(&result.NetGGRUSD)
+	p.money(&result.TotalWageredValue)
+	p.money(&result.TotalPaidOutValue)
+	p.money(&result.NetGGRValue)
+	for i := range result.ByCurrency {
+		s := &result.ByCurrency[i]
+		p.amount(&s.Wagered, s.Currency)
+		p.amount(&s.PaidOut, s.Currency)
+		p.amount(&s.NetGGR, s.Currency)
+		p.amount(&s.AverageBet, s.Currency)
+		p.amount(&s.LargestWin, s.Currency)
+		p.usd(&s.WageredUSD)
+		p.usd(&s.PaidOutUSD)
+		p.usd(&s.NetGGRUSD)
+		p.usd(&s.LargestWinUSD)
+		p.money(&s.WageredValue)
+		p.money(&s.PaidOutValue)
+		p.money(&s.NetGGRValue)
+		p.money(&s.LargestWinValue)
+	}
+}
+
+// leaderboard presents the ranked value as USD, or as a whole number when
+// the metric is a round count
+func (p presenter) leaderboard(result *models.Leaderboard) {
+	for i := range result.Entries {
+		e := &result.Entries[i]
+		if result.Metric == services.MetricRounds {
+			e.Value = e.Value.Present(0, p.asFloat)
+		} else {
+			p.usd(&e.Value)
+		}
+		p.usd(&e.WageredUSD)
+		p.usd(&e.PaidOutUSD)
+		p.usd(&e.NetLossUSD)
+		p.money(&e.WageredValue)
+		p.money(&e.PaidOutValue)
+		p.money(&e.NetLossValue)
+	}
+}
+
+func (p presenter) round(detail *models.RoundDetail) {
+	p.amount(&detail.WagerAmount, detail.Currency)
+	p.amount(&detail.PayoutAmount, detail.Currency)
+	p.amount(&detail.NetResult, detail.Currency)
+	p.usd(&detail.NetResultUSD)
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"admin_statistics_api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultUnsettledLimit = 100
+	maxUnsettledLimit     = 1000
+)
+
+type RoundHandler struct {
+	service    *services.RoundService
+	currencies *services.CurrencyRegistry
+	location   *time.Location
+}
+
+// NewRoundHandler creates a handler that reads dates in location unless a
+// request passes tz and presents amounts with the precision of their
+// currency in the registry
+func NewRoundHandler(service *services.RoundService, currencies *services.CurrencyRegistry, location *time.Location) *RoundHandler {
+	return &RoundHandler{
+		service:    service,
+		currencies: currencies,
+		location:   location,
+	}
+}
+
+// GetRound handles GET /rounds/:round_id
+func (h *RoundHandler) GetRound(c *gin.Context) {
+	roundID := c.Param("round_id")
+
+	if err := rejectReportCurrency(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid report currency",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	amounts, err := amountPresenter(c, h.currencies)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid amount format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	result, err := h.service.GetRound(c.Request.Context(), roundID)
+	if errors.Is(err, services.ErrRoundNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Round not found",
+			"details": "No transactions exist for round " + roundID,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load round",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	amounts.round(result)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// GetUnsettledRounds handles GET /rounds/unsettled
+func (h *RoundHandler) GetUnsettledRounds(c *gin.Context) {
+	timeRange, err := parseTimeRange(c, h.location)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid date parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := rejectReportCurrency(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid report currency",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	amounts, err := amountPresenter(c, h.currencies)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid amount format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	limit := defaultUnsettledLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxUnsettledLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid limit parameter",
+				"details": "limit must be a number between 1 and " + strconv.Itoa(maxUnsettledLimit),
+			})
+			return
+		}
+	}
+
+	results, err := h.service.GetUnsettledRounds(c.Request.Context(), timeRange.From, timeRange.To, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to find unsettled rounds",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	for i := range results {
+		amounts.round(&results[i])
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": timeRange.data(gin.H{
+			"unsettled_rounds": results,
+		}),
+	})
+}
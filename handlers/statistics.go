@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"admin_statistics_api/models"
 	"admin_statistics_api/services"
+	"admin_statistics_api/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -12,77 +17,217 @@ import (
 )
 
 type StatisticsHandler struct {
-	service   *services.StatisticsService
-	validator *validator.Validate
+	service    *services.StatisticsService
+	currencies *services.CurrencyRegistry
+	validator  *validator.Validate
+	location   *time.Location
 }
 
-type TimeRangeQuery struct {
-	From string `form:"from" validate:"required" binding:"required"`
-	To   string `form:"to" validate:"required" binding:"required"`
+type LeaderboardParams struct {
+	Metric   string `form:"metric" validate:"omitempty,oneof=wagered won net_loss rounds"`
+	Currency string `form:"currency" validate:"omitempty,known_currency"`
+	Limit    int    `form:"limit" validate:"omitempty,min=1,max=1000"`
 }
 
-func NewStatisticsHandler() *StatisticsHandler {
+// ComparisonParams selects the window a range is compared against
+type ComparisonParams struct {
+	Compare string `form:"compare" validate:"omitempty,oneof=previous_period previous_year"`
+}
+
+// ValuationParams selects the rates GGR and wager volume are revalued at
+type ValuationParams struct {
+	Valuation string `form:"valuation" validate:"omitempty,oneof=booked spot asof"`
+	AsOf      string `form:"asof"`
+}
+
+// ReportParams selects the currency fiat results are reported in
+type ReportParams struct {
+	ReportCurrency string `form:"report_currency" validate:"omitempty,alphanum,max=16"`
+}
+
+type WagerVolumeParams struct {
+	ComparisonParams
+	ValuationParams
+	ReportParams
+	Granularity string `form:"granularity" validate:"omitempty,oneof=minute hour day week month quarter year"`
+}
+
+// NewStatisticsHandler creates a handler that reads dates in location
+// unless a request passes tz and presents amounts with the precision of
+// their currency in the registry
+func NewStatisticsHandler(service *services.StatisticsService, currencies *services.CurrencyRegistry, location *time.Location) *StatisticsHandler {
+	validate := validator.New()
+	utils.RegisterCurrencyValidations(validate, currencies)
 	return &StatisticsHandler{
-		service:   services.NewStatisticsService(),
-		validator: validator.New(),
+		service:    service,
+		currencies: currencies,
+		validator:  validate,
+		location:   location,
 	}
 }
 
-func (h *StatisticsHandler) parseTimeRange(c *gin.Context) (time.Time, time.Time, error) {
-	var query TimeRangeQuery
-	if err := c.ShouldBindQuery(&query); err != nil {
-		return time.Time{}, time.Time{}, err
-	}
+// comparisonData describes the comparison window and the deltas against it
+func comparisonData(window requestRange, mode string, comparison *models.PeriodComparison) gin.H {
+	return window.data(gin.H{
+		"compare":        mode,
+		"byCurrency":     comparison.ByCurrency,
+		"totalUSD":       comparison.TotalUSD,
+		"total":          comparison.Total,
+		"reportCurrency": comparison.ReportCurrency,
+	})
+}
 
-	// Parse from date
-	from, err := time.Parse("2006-01-02", query.From)
-	if err != nil {
-		return time.Time{}, time.Time{}, err
+// bindComparison reads the compare query parameter
+func (h *StatisticsHandler) bindComparison(c *gin.Context) (string, error) {
+	var params ComparisonParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		return "", err
 	}
+	if err := h.validator.Struct(params); err != nil {
+		return "", err
+	}
+	return params.Compare, nil
+}
 
-	// Parse to date
-	to, err := time.Parse("2006-01-02", query.To)
-	if err != nil {
-		return time.Time{}, time.Time{}, err
+// bindReportCurrency reads the report_currency query parameter, which
+// defaults to USD and must otherwise be a registered currency
+func (h *StatisticsHandler) bindReportCurrency(c *gin.Context) (string, error) {
+	var params ReportParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		return "", err
+	}
+	if err := h.validator.Struct(params); err != nil {
+		return "", err
+	}
+	if params.ReportCurrency == "" {
+		return services.QuoteUSD, nil
+	}
+	currency := strings.ToUpper(params.ReportCurrency)
+	if currency != services.QuoteUSD && !h.currencies.Known(currency) {
+		return "", fmt.Errorf("%s: %w", currency, services.ErrUnknownCurrency)
 	}
+	return currency, nil
+}
 
-	// Set to end of day for 'to' date
-	to = to.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+// rejectReportCurrency refuses report_currency on endpoints that return
+// amounts in their own currency, which have nothing to report it in
+func rejectReportCurrency(c *gin.Context) error {
+	if _, ok := c.GetQuery("report_currency"); ok {
+		return errors.New("report_currency is not supported here, amounts are returned in their own currency")
+	}
+	return nil
+}
 
-	// Validate date range
-	if from.After(to) {
-		return time.Time{}, time.Time{}, gin.Error{
-			Err:  nil,
-			Type: gin.ErrorTypePublic,
-			Meta: "from date cannot be after to date",
-		}
+// bindValuation reads the valuation and asof query parameters, returning
+// the mode and the instant to take rates at. A date as asof means the end
+// of that day in loc. The booked valuation needs no rates and is returned
+// as an empty mode.
+func (h *StatisticsHandler) bindValuation(c *gin.Context, loc *time.Location) (string, time.Time, error) {
+	var params ValuationParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		return "", time.Time{}, err
+	}
+	if err := h.validator.Struct(params); err != nil {
+		return "", time.Time{}, err
+	}
+	if params.AsOf != "" && params.Valuation != services.ValuationAsOf {
+		return "", time.Time{}, errors.New("asof is only accepted with valuation=asof")
 	}
 
-	// Validate dates are not in the future
 	now := time.Now()
-	if from.After(now) || to.After(now) {
-		return time.Time{}, time.Time{}, gin.Error{
-			Err:  nil,
-			Type: gin.ErrorTypePublic,
-			Meta: "dates cannot be in the future",
+	switch params.Valuation {
+	case services.ValuationSpot:
+		return params.Valuation, now.In(loc), nil
+	case services.ValuationAsOf:
+		if params.AsOf == "" {
+			return "", time.Time{}, errors.New("asof is required with valuation=asof")
 		}
+		asOf, isDate, err := parseTimeBound(params.AsOf, loc)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("asof: %v", err)
+		}
+		if isDate {
+			asOf = asOf.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			if asOf.After(now) {
+				asOf = now
+			}
+		}
+		if asOf.After(now) {
+			return "", time.Time{}, errors.New("asof cannot be in the future")
+		}
+		return params.Valuation, asOf.In(loc), nil
+	default:
+		return "", time.Time{}, nil
 	}
-
-	return from, to, nil
 }
 
 // GetGrossGamingRevenue handles GET /gross_gaming_rev
 func (h *StatisticsHandler) GetGrossGamingRevenue(c *gin.Context) {
-	from, to, err := h.parseTimeRange(c)
+	compare, err := h.bindComparison(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid comparison parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	timeRange, err := parseTimeRange(c, h.location)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid date parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	format, err := responseFormat(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid date parameters",
+			"error":   "Invalid format parameter",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	results, err := h.service.GetGrossGamingRevenue(from, to)
+	amounts, err := amountPresenter(c, h.currencies)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid amount format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	valuation, asOf, err := h.bindValuation(c, timeRange.Location)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid valuation parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	reportCurrency, err := h.bindReportCurrency(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid report currency",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	data := gin.H{}
+	var results []models.GrossGamingRevenue
+	var window requestRange
+	var comparison *models.PeriodComparison
+	if compare != "" {
+		window = timeRange.comparison(compare)
+		results, comparison, err = h.service.CompareGrossGamingRevenue(c.Request.Context(),
+			timeRange.From, timeRange.To, window.From, window.To)
+	} else {
+		results, err = h.service.GetGrossGamingRevenue(c.Request.Context(), timeRange.From, timeRange.To)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to calculate gross gaming revenue",
@@ -91,28 +236,123 @@ func (h *StatisticsHandler) GetGrossGamingRevenue(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"data": gin.H{
-			"from":                 from.Format("2006-01-02"),
-			"to":                   to.Format("2006-01-02"),
-			"gross_gaming_revenue": results,
-		},
-	})
+	err = h.service.ReportGrossGamingRevenue(c.Request.Context(), results, reportCurrency, timeRange.From, timeRange.To)
+	if err == nil && comparison != nil {
+		err = h.service.ReportComparison(c.Request.Context(), comparison, reportCurrency, timeRange.To, window.To)
+	}
+	if errors.Is(err, services.ErrRateNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid report currency",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to convert gross gaming revenue",
+			"details": err.Error(),
+		})
+		return
+	}
+	data["report_currency"] = reportCurrency
+	if comparison != nil {
+		amounts.comparison(comparison)
+		data["comparison"] = comparisonData(window, compare, comparison)
+	}
+
+	if valuation != "" {
+		summary, err := h.service.RevalueGrossGamingRevenue(c.Request.Context(), results, valuation, asOf)
+		if errors.Is(err, services.ErrRateNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid valuation parameters",
+				"details": err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to revalue gross gaming revenue",
+				"details": err.Error(),
+			})
+			return
+		}
+		amounts.valuation(summary)
+		data["valuation"] = summary
+	}
+	amounts.ggr(results)
+	data["gross_gaming_revenue"] = results
+
+	render(c, format, timeRange, data, ggrTable(results))
 }
 
-// GetDailyWagerVolume handles GET /daily_wager_volume
+// GetDailyWagerVolume handles GET /daily_wager_volume, the day granularity
+// of /wager_volume in its original response shape
 func (h *StatisticsHandler) GetDailyWagerVolume(c *gin.Context) {
-	from, to, err := h.parseTimeRange(c)
+	compare, err := h.bindComparison(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid date parameters",
+			"error":   "Invalid comparison parameters",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	results, err := h.service.GetDailyWagerVolume(from, to)
+	timeRange, err := parseTimeRange(c, h.location)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid date parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	format, err := responseFormat(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid format parameter",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	amounts, err := amountPresenter(c, h.currencies)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid amount format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	valuation, asOf, err := h.bindValuation(c, timeRange.Location)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid valuation parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	reportCurrency, err := h.bindReportCurrency(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid report currency",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	data := gin.H{}
+	var results []models.DailyWagerVolume
+	var window requestRange
+	var comparison *models.PeriodComparison
+	if compare != "" {
+		window = timeRange.comparison(compare)
+		results, comparison, err = h.service.CompareDailyWagerVolume(c.Request.Context(),
+			timeRange.From, timeRange.To, window.From, window.To, timeRange.Location)
+	} else {
+		results, err = h.service.GetDailyWagerVolume(c.Request.Context(), timeRange.From, timeRange.To, timeRange.Location)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to calculate daily wager volume",
@@ -121,14 +361,194 @@ func (h *StatisticsHandler) GetDailyWagerVolume(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"data": gin.H{
-			"from":                from.Format("2006-01-02"),
-			"to":                  to.Format("2006-01-02"),
-			"daily_wager_volume": results,
-		},
-	})
+	err = h.service.ReportDailyWagerVolume(c.Request.Context(), results, reportCurrency, timeRange.From, timeRange.To, timeRange.Location)
+	if err == nil && comparison != nil {
+		err = h.service.ReportComparison(c.Request.Context(), comparison, reportCurrency, timeRange.To, window.To)
+	}
+	if errors.Is(err, services.ErrRateNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid report currency",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to convert daily wager volume",
+			"details": err.Error(),
+		})
+		return
+	}
+	data["report_currency"] = reportCurrency
+	if comparison != nil {
+		amounts.comparison(comparison)
+		data["comparison"] = comparisonData(window, compare, comparison)
+	}
+
+	if valuation != "" {
+		summary, err := h.service.RevalueDailyWagerVolume(c.Request.Context(), results, valuation, asOf)
+		if errors.Is(err, services.ErrRateNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid valuation parameters",
+				"details": err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to revalue daily wager volume",
+				"details": err.Error(),
+			})
+			return
+		}
+		amounts.valuation(summary)
+		data["valuation"] = summary
+	}
+	amounts.dailyWagerVolume(results)
+	data["daily_wager_volume"] = results
+
+	render(c, format, timeRange, data, dailyWagerVolumeTable(results))
+}
+
+// GetWagerVolume handles GET /wager_volume
+func (h *StatisticsHandler) GetWagerVolume(c *gin.Context) {
+	var params WagerVolumeParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid wager volume parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err := h.validator.Struct(params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid wager volume parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+	if params.Granularity == "" {
+		params.Granularity = services.GranularityDay
+	}
+
+	timeRange, err := parseTimeRange(c, h.location)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid date parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	format, err := responseFormat(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid format parameter",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	amounts, err := amountPresenter(c, h.currencies)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid amount format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	valuation, asOf, err := h.bindValuation(c, timeRange.Location)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid valuation parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	reportCurrency, err := h.bindReportCurrency(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid report currency",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	data := gin.H{"granularity": params.Granularity}
+	var results []models.WagerVolume
+	var window requestRange
+	var comparison *models.PeriodComparison
+	if params.Compare != "" {
+		window = timeRange.comparison(params.Compare)
+		results, comparison, err = h.service.CompareWagerVolume(c.Request.Context(),
+			timeRange.From, timeRange.To, window.From, window.To, params.Granularity, timeRange.Location)
+	} else {
+		results, err = h.service.GetWagerVolume(c.Request.Context(), timeRange.From, timeRange.To, params.Granularity, timeRange.Location)
+	}
+	if errors.Is(err, services.ErrTooManyBuckets) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid wager volume parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to calculate wager volume",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	err = h.service.ReportWagerVolume(c.Request.Context(), results, reportCurrency, timeRange.From, timeRange.To, params.Granularity, timeRange.Location)
+	if err == nil && comparison != nil {
+		err = h.service.ReportComparison(c.Request.Context(), comparison, reportCurrency, timeRange.To, window.To)
+	}
+	if errors.Is(err, services.ErrRateNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid report currency",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to convert wager volume",
+			"details": err.Error(),
+		})
+		return
+	}
+	data["report_currency"] = reportCurrency
+	if comparison != nil {
+		amounts.comparison(comparison)
+		data["comparison"] = comparisonData(window, params.Compare, comparison)
+	}
+
+	if valuation != "" {
+		summary, err := h.service.RevalueWagerVolume(c.Request.Context(), results, valuation, asOf)
+		if errors.Is(err, services.ErrRateNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid valuation parameters",
+				"details": err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to revalue wager volume",
+				"details": err.Error(),
+			})
+			return
+		}
+		amounts.valuation(summary)
+		data["valuation"] = summary
+	}
+	amounts.wagerVolume(results)
+	data["wager_volume"] = results
+
+	render(c, format, timeRange, data, wagerVolumeTable(results, params.Granularity, timeRange.Location))
 }
 
 // GetUserWagerPercentile handles GET /user/:user_id/wager_percentile
@@ -144,16 +564,43 @@ func (h *StatisticsHandler) GetUserWagerPercentile(c *gin.Context) {
 		return
 	}
 
-	from, to, err := h.parseTimeRange(c)
+	timeRange, err := parseTimeRange(c, h.location)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid date parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	format, err := responseFormat(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid format parameter",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	amounts, err := amountPresenter(c, h.currencies)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid amount format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	reportCurrency, err := h.bindReportCurrency(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid date parameters",
+			"error":   "Invalid report currency",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	result, err := h.service.GetUserWagerPercentile(userID, from, to)
+	result, err := h.service.GetUserWagerPercentile(c.Request.Context(), userID, timeRange.From, timeRange.To)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to calculate user wager percentile",
@@ -162,14 +609,203 @@ func (h *StatisticsHandler) GetUserWagerPercentile(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"data": gin.H{
-			"from":             from.Format("2006-01-02"),
-			"to":               to.Format("2006-01-02"),
-			"user_percentile": result,
-		},
+	err = h.service.ReportUserWagerPercentile(c.Request.Context(), result, reportCurrency, timeRange.From, timeRange.To)
+	if errors.Is(err, services.ErrRateNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid report currency",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to convert user wager percentile",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	amounts.userPercentile(result)
+	render(c, format, timeRange, gin.H{"user_percentile": result, "report_currency": reportCurrency}, userPercentileTable(result))
+}
+
+// GetUserSummary handles GET /user/:user_id/summary
+func (h *StatisticsHandler) GetUserSummary(c *gin.Context) {
+	// Parse user ID
+	userID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid user ID format",
+			"details": "User ID must be a valid MongoDB ObjectID",
+		})
+		return
+	}
+
+	timeRange, err := parseTimeRange(c, h.location)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid date parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	format, err := responseFormat(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid format parameter",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	amounts, err := amountPresenter(c, h.currencies)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid amount format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	reportCurrency, err := h.bindReportCurrency(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid report currency",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	result, err := h.service.GetUserSummary(c.Request.Context(), userID, timeRange.From, timeRange.To)
+	if errors.Is(err, services.ErrNoUserActivity) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "No user activity",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to summarise user activity",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	err = h.service.ReportUserSummary(c.Request.Context(), result, reportCurrency, timeRange.From, timeRange.To)
+	if errors.Is(err, services.ErrRateNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid report currency",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to convert user summary",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	amounts.userSummary(result)
+	render(c, format, timeRange, gin.H{"user_summary": result, "report_currency": reportCurrency}, userSummaryTable(result, timeRange.Location))
+}
+
+// GetLeaderboard handles GET /leaderboard
+func (h *StatisticsHandler) GetLeaderboard(c *gin.Context) {
+	var params LeaderboardParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid leaderboard parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err := h.validator.Struct(params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid leaderboard parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+	if params.Metric == "" {
+		params.Metric = services.MetricWagered
+	}
+	if params.Limit == 0 {
+		params.Limit = 10
+	}
+
+	timeRange, err := parseTimeRange(c, h.location)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid date parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	format, err := responseFormat(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid format parameter",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	amounts, err := amountPresenter(c, h.currencies)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid amount format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	reportCurrency, err := h.bindReportCurrency(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid report currency",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	result, err := h.service.GetLeaderboard(c.Request.Context(), services.LeaderboardQuery{
+		Metric:   params.Metric,
+		Currency: params.Currency,
+		From:     timeRange.From,
+		To:       timeRange.To,
+		Limit:    params.Limit,
 	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to build leaderboard",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	err = h.service.ReportLeaderboard(c.Request.Context(), result, reportCurrency, timeRange.From, timeRange.To)
+	if errors.Is(err, services.ErrRateNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid report currency",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to convert leaderboard",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	amounts.leaderboard(result)
+	render(c, format, timeRange, gin.H{"leaderboard": result, "report_currency": reportCurrency}, leaderboardTable(result))
 }
 
 // HealthCheck handles GET /health
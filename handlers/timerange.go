@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"admin_statistics_api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Range bounds accepted by the bounds query parameter
+const (
+	boundsClosed   = "closed"
+	boundsHalfOpen = "half_open"
+)
+
+// Comparison windows accepted by the compare query parameter
+const (
+	comparePeriod = "previous_period"
+	compareYear   = "previous_year"
+)
+
+// Named periods accepted by the period query parameter, besides the
+// calendar forms 2024-03, 2024-W12 and 2024-Q1
+const (
+	periodToday       = "today"
+	periodYesterday   = "yesterday"
+	periodLast7Days   = "last_7_days"
+	periodLast30Days  = "last_30_days"
+	periodMonthToDate = "month_to_date"
+	periodPrevMonth   = "previous_month"
+	periodYearToDate  = "year_to_date"
+)
+
+var (
+	epochPattern   = regexp.MustCompile(`^-?[0-9]+$`)
+	monthPattern   = regexp.MustCompile(`^([0-9]{4})-([0-9]{2})$`)
+	weekPattern    = regexp.MustCompile(`^([0-9]{4})-W([0-9]{2})$`)
+	quarterPattern = regexp.MustCompile(`^([0-9]{4})-Q([1-4])$`)
+)
+
+// TimeRangeQuery selects a range either with from and to or with a period
+type TimeRangeQuery struct {
+	From   string `form:"from"`
+	To     string `form:"to"`
+	Period string `form:"period"`
+	TZ     string `form:"tz"`
+	Bounds string `form:"bounds" binding:"omitempty,oneof=closed half_open"`
+}
+
+// requestRange is the resolved range of a request. From and To are the
+// first and last included instants, which is what the services query
+// with. End is the upper bound as the client meant it: To itself for
+// closed ranges and the first excluded instant for half-open ones.
+// Location is the time zone dates were read in and Period the shortcut
+// the range was resolved from, if any.
+type requestRange struct {
+	From     time.Time
+	To       time.Time
+	End      time.Time
+	HalfOpen bool
+	Location *time.Location
+	Period   string
+}
+
+// data returns a response payload echoing the resolved range alongside
+// the given fields
+func (r requestRange) data(fields gin.H) gin.H {
+	bounds := boundsClosed
+	if r.HalfOpen {
+		bounds = boundsHalfOpen
+	}
+	fields["from"] = r.From.In(r.Location).Format(time.RFC3339Nano)
+	fields["to"] = r.End.In(r.Location).Format(time.RFC3339Nano)
+	fields["bounds"] = bounds
+	fields["timezone"] = r.Location.String()
+	if r.Period != "" {
+		fields["period"] = r.Period
+	}
+	return fields
+}
+
+// parseTimeRange reads the from/to query parameters shared by the
+// statistics endpoints. Each bound may be a date, an RFC 3339 timestamp or
+// Unix epoch seconds. Dates are taken in the tz query parameter, or in
+// defaultLocation when it is absent; a date as from starts at its
+// midnight and a date as to covers the whole day.
+//
+// Ranges are closed by default. With bounds=half_open the range is
+// [from, to) and a date as to excludes that day.
+//
+// Instead of from and to a period shortcut may be given; see
+// resolvePeriod. Periods always resolve to half-open ranges.
+func parseTimeRange(c *gin.Context, defaultLocation *time.Location) (requestRange, error) {
+	var query TimeRangeQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		return requestRange{}, err
+	}
+
+	loc := defaultLocation
+	if query.TZ != "" {
+		var err error
+		loc, err = utils.LoadTimezone(query.TZ)
+		if err != nil {
+			return requestRange{}, err
+		}
+	}
+
+	if query.Period != "" {
+		if query.From != "" || query.To != "" {
+			return requestRange{}, errors.New("period cannot be combined with from or to")
+		}
+		return periodRange(query.Period, loc, time.Now())
+	}
+	if query.From == "" || query.To == "" {
+		return requestRange{}, errors.New("from and to are required unless period is given")
+	}
+	halfOpen := query.Bounds == boundsHalfOpen
+
+	from, _, err := parseTimeBound(query.From, loc)
+	if err != nil {
+		return requestRange{}, fmt.Errorf("from: %v", err)
+	}
+
+	end, isDate, err := parseTimeBound(query.To, loc)
+	if err != nil {
+		return requestRange{}, fmt.Errorf("to: %v", err)
+	}
+
+	// A date as to includes the whole day in a closed range. Days are not
+	// always 24 hours long outside UTC, so step to the next midnight.
+	if isDate && !halfOpen {
+		end = end.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	// The services query inclusive ranges; MongoDB keeps milliseconds, so
+	// stepping back a nanosecond excludes exactly the end instant
+	to := end
+	if halfOpen {
+		to = end.Add(-time.Nanosecond)
+	}
+
+	// Validate date range
+	if from.After(to) {
+		return requestRange{}, errors.New("from cannot be after to")
+	}
+
+	// Validate dates are not in the future
+	now := time.Now()
+	if from.After(now) || to.After(now) {
+		return requestRange{}, errors.New("dates cannot be in the future")
+	}
+
+	return requestRange{
+		From:     from,
+		To:       to,
+		End:      end,
+		HalfOpen: halfOpen,
+		Location: loc,
+	}, nil
+}
+
+// parseTimeBound parses a date in loc, an RFC 3339 timestamp or Unix epoch
+// seconds, reporting whether the value was a plain date
+func parseTimeBound(value string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, false, nil
+	}
+	if epochPattern.MatchString(value) {
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err == nil {
+			return time.Unix(seconds, 0), false, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("%q is not a date, RFC 3339 timestamp or Unix epoch seconds", value)
+}
+
+// periodRange resolves a period shortcut into a half-open range. Periods
+// that have not ended yet stop at now.
+func periodRange(period string, loc *time.Location, now time.Time) (requestRange, error) {
+	start, end, err := resolvePeriod(period, loc, now)
+	if err != nil {
+		return requestRange{}, err
+	}
+
+	if start.After(now) {
+		return requestRange{}, errors.New("dates cannot be in the future")
+	}
+	if end.After(now) {
+		end = now
+	}
+
+	return requestRange{
+		From:     start,
+		To:       end.Add(-time.Nanosecond),
+		End:      end,
+		HalfOpen: true,
+		Location: loc,
+		Period:   period,
+	}, nil
+}
+
+// resolvePeriod returns the start and exclusive end of a period, with
+// calendar boundaries taken in loc. Relative periods count back from the
+// day containing now and include it: last_7_days is today and the six
+// days before it. Weeks are ISO weeks starting on Monday.
+func resolvePeriod(period string, loc *time.Location, now time.Time) (time.Time, time.Time, error) {
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+
+	switch period {
+	case periodToday:
+		return today, today.AddDate(0, 0, 1), nil
+	case periodYesterday:
+		return today.AddDate(0, 0, -1), today, nil
+	case periodLast7Days:
+		return today.AddDate(0, 0, -6), today.AddDate(0, 0, 1), nil
+	case periodLast30Days:
+		return today.AddDate(0, 0, -29), today.AddDate(0, 0, 1), nil
+	case periodMonthToDate:
+		return month, month.AddDate(0, 1, 0), nil
+	case periodPrevMonth:
+		return month.AddDate(0, -1, 0), month, nil
+	case periodYearToDate:
+		year := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, loc)
+		return year, year.AddDate(1, 0, 0), nil
+	}
+
+	if m := monthPattern.FindStringSubmatch(period); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		number, _ := strconv.Atoi(m[2])
+		if number < 1 || number > 12 {
+			return time.Time{}, time.Time{}, fmt.Errorf("period %q has no month %d", period, number)
+		}
+		start := time.Date(year, time.Month(number), 1, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 1, 0), nil
+	}
+
+	if m := weekPattern.FindStringSubmatch(period); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		number, _ := strconv.Atoi(m[2])
+		// Week 1 is the week containing January 4th
+		jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, loc)
+		offset := (int(jan4.Weekday()) + 6) % 7
+		start := time.Date(year, time.January, 4-offset+(number-1)*7, 0, 0, 0, 0, loc)
+		if isoYear, isoWeek := start.ISOWeek(); number < 1 || isoYear != year || isoWeek != number {
+			return time.Time{}, time.Time{}, fmt.Errorf("period %q has no week %d", period, number)
+		}
+		return start, start.AddDate(0, 0, 7), nil
+	}
+
+	if m := quarterPattern.FindStringSubmatch(period); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		number, _ := strconv.Atoi(m[2])
+		start := time.Date(year, time.Month((number-1)*3+1), 1, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 3, 0), nil
+	}
+
+	return time.Time{}, time.Time{}, fmt.Errorf("unknown period %q", period)
+}
+
+// comparison returns the window a range is compared against.
+// previous_year is the same range a calendar year earlier. previous_period
+// is the preceding months when the range covers whole calendar months and
+// otherwise the equally long window ending where the range starts.
+func (r requestRange) comparison(mode string) requestRange {
+	start := r.From.In(r.Location)
+	end := r.End.In(r.Location)
+	if !r.HalfOpen {
+		end = r.To.Add(time.Nanosecond).In(r.Location)
+	}
+
+	var prevStart, prevEnd time.Time
+	switch mode {
+	case compareYear:
+		prevStart, prevEnd = start.AddDate(-1, 0, 0), end.AddDate(-1, 0, 0)
+	default:
+		if months := wholeMonths(start, end); months > 0 {
+			prevStart = start.AddDate(0, -months, 0)
+		} else {
+			prevStart = start.Add(-end.Sub(start))
+		}
+		prevEnd = start
+	}
+
+	return requestRange{
+		From:     prevStart,
+		To:       prevEnd.Add(-time.Nanosecond),
+		End:      prevEnd,
+		HalfOpen: true,
+		Location: r.Location,
+	}
+}
+
+// wholeMonths returns how many calendar months [start, end) spans when both
+// bounds are midnights on the first of a month, and 0 otherwise
+func wholeMonths(start, end time.Time) int {
+	isMonthStart := func(t time.Time) bool {
+		return t.Day() == 1 && t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0
+	}
+	if !isMonthStart(start) || !isMonthStart(end) {
+		return 0
+	}
+	return (end.Year()-start.Year())*12 + int(end.Month()) - int(start.Month())
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// location loads a time zone, skipping the test when tzdata is missing
+func location(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s is not available: %v", name, err)
+	}
+	return loc
+}
+
+// queryContext returns a gin context for a GET request with the given query
+func queryContext(query string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/api/statistics/gross_gaming_rev?"+query, nil)
+	return c
+}
+
+func TestParseTimeRange(t *testing.T) {
+	location(t, "Europe/Malta")
+
+	tests := []struct {
+		name     string
+		query    string
+		from, to string
+		end      string
+		halfOpen bool
+		wantErr  string
+	}{
+		{
+			name:  "dates cover whole days in UTC",
+			query: "from=2024-03-01&to=2024-03-02",
+			from:  "2024-03-01T00:00:00Z",
+			to:    "2024-03-02T23:59:59.999999999Z",
+			end:   "2024-03-02T23:59:59.999999999Z",
+		},
+		{
+			name:  "dates are taken in tz",
+			query: "from=2024-03-01&to=2024-03-01&tz=Europe/Malta",
+			from:  "2024-03-01T00:00:00+01:00",
+			to:    "2024-03-01T23:59:59.999999999+01:00",
+			end:   "2024-03-01T23:59:59.999999999+01:00",
+		},
+		{
+			// Clocks go forward on 2024-03-31, so the day is 23 hours long
+			name:  "closed day with a spring-forward transition",
+			query: "from=2024-03-31&to=2024-03-31&tz=Europe/Malta",
+			from:  "2024-03-31T00:00:00+01:00",
+			to:    "2024-03-31T23:59:59.999999999+02:00",
+			end:   "2024-03-31T23:59:59.999999999+02:00",
+		},
+		{
+			// Clocks go back on 2024-10-27, so the day is 25 hours long
+			name:     "half-open day with a fall-back transition",
+			query:    "from=2024-10-27&to=2024-10-28&tz=Europe/Malta&bounds=half_open",
+			from:     "2024-10-27T00:00:00+02:00",
+			to:       "2024-10-27T23:59:59.999999999+01:00",
+			end:      "2024-10-28T00:00:00+01:00",
+			halfOpen: true,
+		},
+		{
+			name:  "timestamps and epoch seconds",
+			query: "from=2024-03-01T10:00:00%2B02:00&to=1709330400",
+			from:  "2024-03-01T08:00:00Z",
+			to:    "2024-03-01T22:00:00Z",
+			end:   "2024-03-01T22:00:00Z",
+		},
+		{
+			name:    "unknown tz",
+			query:   "from=2024-03-01&to=2024-03-02&tz=Mars/Olympus",
+			wantErr: "Mars/Olympus",
+		},
+		{
+			name:    "from after to",
+			query:   "from=2024-03-02&to=2024-03-01",
+			wantErr: "from cannot be after to",
+		},
+		{
+			name:    "future dates",
+			query:   "from=2024-03-01&to=2999-01-01",
+			wantErr: "future",
+		},
+		{
+			name:    "missing to",
+			query:   "from=2024-03-01",
+			wantErr: "from and to are required",
+		},
+		{
+			name:    "period with from",
+			query:   "from=2024-03-01&period=2024-03",
+			wantErr: "period cannot be combined",
+		},
+		{
+			name:    "unparseable bound",
+			query:   "from=yesterday&to=2024-03-01",
+			wantErr: "from:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := parseTimeRange(queryContext(tt.query), time.UTC)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTimeRange: %v", err)
+			}
+
+			for _, bound := range []struct {
+				name string
+				got  time.Time
+				want string
+			}{{"from", r.From, tt.from}, {"to", r.To, tt.to}, {"end", r.End, tt.end}} {
+				want, _ := time.Parse(time.RFC3339Nano, bound.want)
+				if !bound.got.Equal(want) {
+					t.Errorf("got %s %s, want %s", bound.name, bound.got.Format(time.RFC3339Nano), bound.want)
+				}
+			}
+			if r.HalfOpen != tt.halfOpen {
+				t.Errorf("got half-open %v, want %v", r.HalfOpen, tt.halfOpen)
+			}
+		})
+	}
+}
+
+func TestParseTimeRangeDefaultLocation(t *testing.T) {
+	malta := location(t, "Europe/Malta")
+
+	r, err := parseTimeRange(queryContext("from=2024-03-01&to=2024-03-01"), malta)
+	if err != nil {
+		t.Fatalf("parseTimeRange: %v", err)
+	}
+	if want := time.Date(2024, time.March, 1, 0, 0, 0, 0, malta); !r.From.Equal(want) || r.Location != malta {
+		t.Errorf("got from %v in %v, want %v", r.From, r.Location, want)
+	}
+}
+
+func TestPeriodRange(t *testing.T) {
+	malta := location(t, "Europe/Malta")
+	// A Wednesday, shortly after midnight in Malta but still the day
+	// before in UTC
+	now := time.Date(2024, time.April, 10, 0, 30, 0, 0, malta)
+
+	tests := []struct {
+		name       string
+		period     string
+		loc        *time.Location
+		start, end string
+		wantErr    string
+	}{
+		{name: "today is the local day so far", period: "today", loc: malta, start: "2024-04-10T00:00:00+02:00", end: "2024-04-10T00:30:00+02:00"},
+		{name: "today in UTC", period: "today", loc: time.UTC, start: "2024-04-09T00:00:00Z", end: "2024-04-09T22:30:00Z"},
+		{name: "yesterday", period: "yesterday", loc: malta, start: "2024-04-09T00:00:00+02:00", end: "2024-04-10T00:00:00+02:00"},
+		{name: "last 7 days include today", period: "last_7_days", loc: malta, start: "2024-04-04T00:00:00+02:00", end: "2024-04-10T00:30:00+02:00"},
+		{name: "previous month spans the DST change", period: "previous_month", loc: malta, start: "2024-03-01T00:00:00+01:00", end: "2024-04-01T00:00:00+02:00"},
+		{name: "month", period: "2024-02", loc: malta, start: "2024-02-01T00:00:00+01:00", end: "2024-03-01T00:00:00+01:00"},
+		{name: "ISO week in the previous calendar year", period: "2021-W01", loc: time.UTC, start: "2021-01-04T00:00:00Z", end: "2021-01-11T00:00:00Z"},
+		{name: "ISO week starting in December", period: "2020-W53", loc: time.UTC, start: "2020-12-28T00:00:00Z", end: "2021-01-04T00:00:00Z"},
+		{name: "ISO week with the fall-back transition", period: "2023-W43", loc: malta, start: "2023-10-23T00:00:00+02:00", end: "2023-10-30T00:00:00+01:00"},
+		{name: "quarter", period: "2024-Q1", loc: malta, start: "2024-01-01T00:00:00+01:00", end: "2024-04-01T00:00:00+02:00"},
+		{name: "no week 53", period: "2024-W53", loc: time.UTC, wantErr: "has no week 53"},
+		{name: "no month 13", period: "2024-13", loc: time.UTC, wantErr: "has no month 13"},
+		{name: "future period", period: "2024-Q3", loc: time.UTC, wantErr: "future"},
+		{name: "unknown period", period: "last_fortnight", loc: time.UTC, wantErr: "unknown period"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := periodRange(tt.period, tt.loc, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("periodRange: %v", err)
+			}
+
+			start, _ := time.Parse(time.RFC3339, tt.start)
+			end, _ := time.Parse(time.RFC3339, tt.end)
+			if !r.From.Equal(start) || !r.End.Equal(end) {
+				t.Errorf("got [%v, %v), want [%s, %s)", r.From, r.End, tt.start, tt.end)
+			}
+			if !r.HalfOpen || !r.To.Equal(end.Add(-time.Nanosecond)) || r.Period != tt.period {
+				t.Errorf("got %+v, want a half-open range for %s", r, tt.period)
+			}
+		})
+	}
+}
+
+func TestComparison(t *testing.T) {
+	malta := location(t, "Europe/Malta")
+	at := func(value string) time.Time {
+		parsed, _ := time.Parse(time.RFC3339, value)
+		return parsed
+	}
+	halfOpen := func(from, end string) requestRange {
+		return requestRange{From: at(from), To: at(end).Add(-time.Nanosecond), End: at(end), HalfOpen: true, Location: malta}
+	}
+
+	tests := []struct {
+		name       string
+		r          requestRange
+		mode       string
+		start, end string
+	}{
+		{
+			name:  "whole months step back by calendar months",
+			r:     halfOpen("2024-03-01T00:00:00+01:00", "2024-04-01T00:00:00+02:00"),
+			mode:  comparePeriod,
+			start: "2024-02-01T00:00:00+01:00",
+			end:   "2024-03-01T00:00:00+01:00",
+		},
+		{
+			name:  "other ranges step back by their length",
+			r:     halfOpen("2024-03-10T00:00:00+01:00", "2024-03-13T00:00:00+01:00"),
+			mode:  comparePeriod,
+			start: "2024-03-07T00:00:00+01:00",
+			end:   "2024-03-10T00:00:00+01:00",
+		},
+		{
+			name:  "previous year",
+			r:     halfOpen("2024-03-10T00:00:00+01:00", "2024-03-13T00:00:00+01:00"),
+			mode:  compareYear,
+			start: "2023-03-10T00:00:00+01:00",
+			end:   "2023-03-13T00:00:00+01:00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.r.comparison(tt.mode)
+			if !got.From.Equal(at(tt.start)) || !got.End.Equal(at(tt.end)) {
+				t.Errorf("got [%v, %v), want [%s, %s)", got.From, got.End, tt.start, tt.end)
+			}
+		})
+	}
+}
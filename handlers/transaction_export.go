@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"admin_statistics_api/models"
+	"admin_statistics_api/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// exportFlushEvery is how many transactions are written between flushes
+// to the client
+const exportFlushEvery = 500
+
+const mimeNDJSON = "application/x-ndjson"
+
+type TransactionExportParams struct {
+	UserID   string `form:"user_id"`
+	Currency string `form:"currency" validate:"omitempty,known_currency"`
+	Type     string `form:"type" validate:"omitempty,oneof=Wager Payout"`
+	Cursor   string `form:"cursor"`
+	Format   string `form:"format" validate:"omitempty,oneof=ndjson csv"`
+}
+
+// exportedTransaction is an NDJSON export line: the transaction plus the
+// cursor to resume the export after it
+type exportedTransaction struct {
+	models.Transaction
+	Cursor string `json:"cursor"`
+}
+
+var transactionCSVHeader = []string{
+	"id", "created_at", "user_id", "round_id", "type", "amount", "currency",
+	"usd_amount", "idempotency_key", "round_slot", "cursor",
+}
+
+// ExportTransactions handles GET /transactions/export. Transactions are
+// streamed as NDJSON or CSV straight from the database cursor; every
+// record carries a cursor that, passed back as ?cursor=, resumes the
+// export right after it.
+func (h *TransactionHandler) ExportTransactions(c *gin.Context) {
+	var params TransactionExportParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid export parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err := h.validator.Struct(params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid export parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := rejectReportCurrency(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid report currency",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	timeRange, err := parseTimeRange(c, h.location)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid date parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	query := services.TransactionQuery{
+		From:     timeRange.From,
+		To:       timeRange.To,
+		Currency: params.Currency,
+		Type:     params.Type,
+	}
+	if params.UserID != "" {
+		userID, err := primitive.ObjectIDFromHex(params.UserID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid user ID format",
+				"details": "User ID must be a valid MongoDB ObjectID",
+			})
+			return
+		}
+		query.UserID = &userID
+	}
+	if params.Cursor != "" {
+		query.After, err = services.ParseTransactionPosition(params.Cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid export parameters",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	format := params.Format
+	if format == "" {
+		format = "ndjson"
+		if c.NegotiateFormat(mimeNDJSON, "text/csv") == "text/csv" {
+			format = "csv"
+		}
+	}
+
+	// Headers are only sent with the first record so that a query that
+	// fails straight away can still be reported as an error
+	started := false
+	start := func() {
+		started = true
+		contentType := mimeNDJSON
+		if format == "csv" {
+			contentType = "text/csv; charset=utf-8"
+		}
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="transactions_%s.%s"`, exportRangeName(timeRange), format))
+		c.Status(http.StatusOK)
+	}
+
+	buffered := bufio.NewWriter(c.Writer)
+	encoder := json.NewEncoder(buffered)
+	csvWriter := csv.NewWriter(buffered)
+	flush := func() error {
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return err
+		}
+		if err := buffered.Flush(); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}
+
+	written := 0
+	err = h.service.Export(c.Request.Context(), query, func(tx models.Transaction) error {
+		if !started {
+			start()
+			if format == "csv" {
+				csvWriter.Write(transactionCSVHeader)
+			}
+		}
+
+		cursor := services.PositionOf(tx).Token()
+		if format == "csv" {
+			csvWriter.Write([]string{
+				tx.ID.Hex(), tx.CreatedAt.UTC().Format("2006-01-02T15:04:05.000Z07:00"), tx.UserID.Hex(),
+				tx.RoundID, tx.Type, tx.Amount.String(), tx.Currency, tx.USDAmount.String(),
+				tx.IdempotencyKey, tx.RoundSlot, cursor,
+			})
+		} else if err := encoder.Encode(exportedTransaction{Transaction: tx, Cursor: cursor}); err != nil {
+			return err
+		}
+
+		written++
+		if written%exportFlushEvery == 0 {
+			return flush()
+		}
+		return nil
+	})
+
+	if !started && err == nil {
+		// Nothing matched: still answer with an empty file
+		start()
+		if format == "csv" {
+			csvWriter.Write(transactionCSVHeader)
+		}
+	}
+	if !started && err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to export transactions",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if flushErr := flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil && c.Request.Context().Err() == nil {
+		// The status line is already sent, so the client only sees a
+		// truncated file and resumes from the last cursor it received
+		log.Printf("Transaction export stopped after %d records: %v", written, err)
+	}
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"admin_statistics_api/models"
+	"admin_statistics_api/services"
+	"admin_statistics_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// maxBatchSize caps the number of transactions accepted by one batch request
+const maxBatchSize = 1000
+
+type TransactionHandler struct {
+	service   *services.TransactionService
+	validator *validator.Validate
+	location  *time.Location
+}
+
+type TransactionBatchRequest struct {
+	Transactions []json.RawMessage `json:"transactions"`
+}
+
+// TransactionResult reports the outcome for one item of a batch
+type TransactionResult struct {
+	Index  int      `json:"index"`
+	ID     string   `json:"id,omitempty"`
+	Status string   `json:"status"`
+	Errors []string `json:"errors,omitempty"`
+	// Existing is the stored transaction for replays and conflicts
+	Existing *models.Transaction `json:"existing,omitempty"`
+}
+
+// NewTransactionHandler creates a handler that accepts the enabled
+// currencies of the registry and whose export reads dates in location
+// unless a request passes tz
+func NewTransactionHandler(service *services.TransactionService, currencies *services.CurrencyRegistry, location *time.Location) *TransactionHandler {
+	return &TransactionHandler{
+		service:   service,
+		validator: utils.NewValidator(currencies),
+		location:  location,
+	}
+}
+
+// prepare decodes and validates a single transaction payload. The
+// idempotency key may come from the Idempotency-Key header instead of the body.
+func (h *TransactionHandler) prepare(ctx context.Context, raw []byte, headerKey string) (models.Transaction, []string) {
+	var req models.TransactionRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return models.Transaction{}, []string{"malformed transaction: " + err.Error()}
+	}
+
+	if headerKey != "" {
+		if req.IdempotencyKey != "" && req.IdempotencyKey != headerKey {
+			return models.Transaction{}, []string{"idempotencyKey does not match the Idempotency-Key header"}
+		}
+		req.IdempotencyKey = headerKey
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return models.Transaction{}, utils.ValidationMessages(err)
+	}
+
+	tx, err := h.service.BuildTransaction(ctx, req)
+	if err != nil {
+		return models.Transaction{}, []string{err.Error()}
+	}
+
+	return tx, nil
+}
+
+// CreateTransaction handles POST /transactions
+func (h *TransactionHandler) CreateTransaction(c *gin.Context) {
+	raw, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	tx, problems := h.prepare(c.Request.Context(), raw, c.GetHeader("Idempotency-Key"))
+	if problems != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid transaction",
+			"details": problems,
+		})
+		return
+	}
+
+	results, err := h.service.Create(c.Request.Context(), []models.Transaction{tx})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to store transaction",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	result := results[0]
+	switch result.Status {
+	case services.IngestCreated:
+		c.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"data":    result.Transaction,
+		})
+	case services.IngestReplayed:
+		// The original result is returned with 200 rather than 201 so
+		// clients can tell a replay from a fresh write
+		c.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"replayed": true,
+			"data":     result.Transaction,
+		})
+	case services.IngestConflict:
+		c.JSON(http.StatusConflict, gin.H{
+			"error":    "Duplicate transaction",
+			"details":  result.Err.Error(),
+			"existing": result.Transaction,
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to store transaction",
+			"details": result.Err.Error(),
+		})
+	}
+}
+
+// CreateTransactionBatch handles POST /transactions/batch
+func (h *TransactionHandler) CreateTransactionBatch(c *gin.Context) {
+	var req TransactionBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(req.Transactions) == 0 || len(req.Transactions) > maxBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid batch size",
+			"details": fmt.Sprintf("transactions must contain between 1 and %d items", maxBatchSize),
+		})
+		return
+	}
+
+	// Validate every item first and only write the ones that passed. Batch
+	// items carry their idempotency keys in the body.
+	results := make([]TransactionResult, len(req.Transactions))
+	var txs []models.Transaction
+	var indexes []int
+	for i, raw := range req.Transactions {
+		results[i].Index = i
+		tx, problems := h.prepare(c.Request.Context(), raw, "")
+		if problems != nil {
+			results[i].Status = "rejected"
+			results[i].Errors = problems
+			continue
+		}
+		txs = append(txs, tx)
+		indexes = append(indexes, i)
+	}
+
+	stored, err := h.service.Create(c.Request.Context(), txs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to store transactions",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	created, replayed := 0, 0
+	for j, index := range indexes {
+		result := stored[j]
+		results[index].Status = result.Status
+		if result.Err != nil {
+			results[index].Errors = []string{result.Err.Error()}
+		}
+
+		switch result.Status {
+		case services.IngestCreated:
+			created++
+			results[index].ID = result.Transaction.ID.Hex()
+		case services.IngestReplayed:
+			replayed++
+			results[index].ID = result.Transaction.ID.Hex()
+			results[index].Existing = result.Transaction
+		case services.IngestConflict:
+			results[index].Existing = result.Transaction
+		}
+	}
+
+	status := http.StatusCreated
+	if created < len(results) {
+		status = http.StatusMultiStatus
+	}
+
+	c.JSON(status, gin.H{
+		"success": created+replayed == len(results),
+		"data": gin.H{
+			"created":  created,
+			"replayed": replayed,
+			"failed":   len(results) - created - replayed,
+			"results":  results,
+		},
+	})
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"time"
 
 	"admin_statistics_api/config"
 	"admin_statistics_api/handlers"
 	"admin_statistics_api/middleware"
+	"admin_statistics_api/models"
+	"admin_statistics_api/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -37,19 +41,93 @@ func main() {
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
 
-	// Initialize handlers
-	statsHandler := handlers.NewStatisticsHandler()
+	// Initialize services
+	store := services.NewMongoTransactionStore(config.DB)
+	cache := config.NewRedisCache(config.RedisClient)
+	// Amounts are converted to USD with the rates stored in exchange_rates,
+	// or with a static rate file when EXCHANGE_RATES_FILE is set
+	rates := config.NewRateProvider(config.DB)
+	// Supported currencies come from the currencies collection, reloaded
+	// periodically so they can change without a redeploy
+	currencies := config.LoadCurrencies(config.DB)
+	statsService := services.NewStatisticsService(store, cache, rates)
+
+	// Keep the daily_stats rollups current as new transactions arrive
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	defer stopWorker()
+	if mode := os.Getenv("ROLLUP_WORKER_MODE"); mode != "off" {
+		pollInterval := 10 * time.Second
+		if interval, err := time.ParseDuration(os.Getenv("ROLLUP_POLL_INTERVAL")); err == nil {
+			pollInterval = interval
+		}
+		feed := services.NewMongoTransactionFeed(config.DB, mode, pollInterval)
+		go services.NewRollupWorker(store, cache, feed).Run(workerCtx)
+	}
+	go currencies.Run(workerCtx, config.CurrencyRefreshInterval())
+
+	transactionService := services.NewTransactionService(store, rates, currencies)
+	roundService := services.NewRoundService(store)
+	apiKeyService := services.NewAPIKeyService(services.NewMongoAPIKeyStore(config.DB), config.APIKeyCacheTTL())
+
+	// Initialize handlers. Dates are read in DEFAULT_TIMEZONE unless a
+	// request passes tz.
+	location := config.DefaultTimezone()
+	statsHandler := handlers.NewStatisticsHandler(statsService, currencies, location)
+	transactionHandler := handlers.NewTransactionHandler(transactionService, currencies, location)
+	roundHandler := handlers.NewRoundHandler(roundService, currencies, location)
+	rateHandler := handlers.NewExchangeRateHandler(rates, location)
+	currencyHandler := handlers.NewCurrencyHandler(currencies)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+
+	// Requests spend points of their client's token bucket, kept in Redis
+	// or in process without it. Each route declares its cost, weighted by
+	// how expensive it is to serve.
+	limiter := config.NewRateLimiter(config.RedisClient)
+	cost := func(points int) gin.HandlerFunc {
+		return middleware.RateLimit(limiter, points)
+	}
 
 	// Public routes (no auth required)
-	router.GET("/health", statsHandler.HealthCheck)
+	router.GET("/health", cost(1), statsHandler.HealthCheck)
 
-	// Protected routes (require authentication)
+	// Protected routes (require a bearer token or API key). Each route
+	// declares the lowest role allowed to call it, higher roles including
+	// lower ones, and the scope API keys need for it.
+	statsRead := middleware.Require(middleware.RoleViewer, models.ScopeStatsRead)
+	usersRead := middleware.Require(middleware.RoleAnalyst, models.ScopeUsersRead)
+	transactionsRead := middleware.Require(middleware.RoleAnalyst, models.ScopeTransactionsRead)
+	transactionsWrite := middleware.Require(middleware.RoleAdmin, models.ScopeTransactionsWrite)
+	admin := middleware.RequireRole(middleware.RoleAdmin)
+
+	// Requests rejected with 401 or 403 cost 10 points from the bucket of
+	// their IP, so credentials cannot be guessed at an unlimited rate
 	api := router.Group("/")
-	api.Use(middleware.AuthMiddleware())
+	api.Use(middleware.LimitFailedAuth(limiter, 10), middleware.AuthMiddleware(config.NewAuthenticator(), apiKeyService))
 	{
-		api.GET("/gross_gaming_rev", statsHandler.GetGrossGamingRevenue)
-		api.GET("/daily_wager_volume", statsHandler.GetDailyWagerVolume)
-		api.GET("/user/:user_id/wager_percentile", statsHandler.GetUserWagerPercentile)
+		api.GET("/gross_gaming_rev", statsRead, cost(5), statsHandler.GetGrossGamingRevenue)
+		api.GET("/wager_volume", statsRead, cost(5), statsHandler.GetWagerVolume)
+		api.GET("/daily_wager_volume", statsRead, cost(5), statsHandler.GetDailyWagerVolume)
+		api.GET("/user/:user_id/wager_percentile", usersRead, cost(20), statsHandler.GetUserWagerPercentile)
+		api.GET("/user/:user_id/summary", usersRead, cost(10), statsHandler.GetUserSummary)
+		api.GET("/leaderboard", statsRead, cost(10), statsHandler.GetLeaderboard)
+
+		api.POST("/transactions", transactionsWrite, cost(1), transactionHandler.CreateTransaction)
+		api.POST("/transactions/batch", transactionsWrite, cost(10), transactionHandler.CreateTransactionBatch)
+		api.GET("/transactions/export", transactionsRead, cost(20), transactionHandler.ExportTransactions)
+
+		api.GET("/rounds/unsettled", transactionsRead, cost(5), roundHandler.GetUnsettledRounds)
+		api.GET("/rounds/:round_id", transactionsRead, cost(1), roundHandler.GetRound)
+
+		api.GET("/currencies", statsRead, cost(1), currencyHandler.ListCurrencies)
+
+		api.GET("/admin/exchange_rates", admin, cost(1), rateHandler.ListSeries)
+		api.GET("/admin/exchange_rates/:base/:quote", admin, cost(1), rateHandler.ListRates)
+		api.POST("/admin/exchange_rates", admin, cost(5), rateHandler.UploadRates)
+
+		api.GET("/admin/api_keys", admin, cost(1), apiKeyHandler.ListKeys)
+		api.POST("/admin/api_keys", admin, cost(1), apiKeyHandler.CreateKey)
+		api.POST("/admin/api_keys/:id/rotate", admin, cost(1), apiKeyHandler.RotateKey)
+		api.DELETE("/admin/api_keys/:id", admin, cost(1), apiKeyHandler.RevokeKey)
 	}
 
 	// Get port from environment or use default
@@ -60,7 +138,7 @@ func main() {
 
 	log.Printf("Server starting on port %s", port)
 	log.Printf("Health check available at: http://localhost:%s/health", port)
-	log.Printf("API endpoints require Authorization header")
+	log.Printf("API endpoints require a Bearer token or an X-API-Key header")
 	
 	if err := router.Run(":" + port); err != nil {
 		log.Fatal("Failed to start server:", err)
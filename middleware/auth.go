@@ -1,40 +1,342 @@
 package middleware
 
 import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
 	"net/http"
-	"os"
+	"strings"
+	"time"
+
+	"admin_statistics_api/models"
+	"admin_statistics_api/services"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// Role is an access level granted by a token. Each role includes the ones
+// below it: admin can do everything an analyst can, and an analyst
+// everything a viewer can.
+type Role string
+
+const (
+	RoleViewer  Role = "viewer"
+	RoleAnalyst Role = "analyst"
+	RoleAdmin   Role = "admin"
+)
+
+var roleLevels = map[Role]int{
+	RoleViewer:  1,
+	RoleAnalyst: 2,
+	RoleAdmin:   3,
+}
+
+// Gin context keys set by AuthMiddleware
+const (
+	principalKey = "principal"
+	// APIKeyIDKey holds the ID of the API key a request was made with
+	APIKeyIDKey = "apiKeyId"
+)
+
+// tokenLeeway absorbs clock skew between the issuer and this server
+const tokenLeeway = 30 * time.Second
+
+// Principal is the authenticated caller of a request: a token subject
+// with roles, or the owner of an API key with scopes
+type Principal struct {
+	Subject string
+	Roles   []Role
+	Scopes  []string
+
+	// APIKeyID and APIKeyName identify the API key a request was made
+	// with and are empty for tokens
+	APIKeyID   string
+	APIKeyName string
+}
+
+// HasRole reports whether the principal holds role or a role above it
+func (p *Principal) HasRole(role Role) bool {
+	for _, held := range p.Roles {
+		if roleLevels[held] >= roleLevels[role] {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope reports whether the principal was granted scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, granted := range p.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyVerifier resolves API key secrets to active keys
+type APIKeyVerifier interface {
+	VerifyKey(ctx context.Context, secret string) (models.APIKey, error)
+}
+
+// AuthConfig configures how bearer tokens are verified
+type AuthConfig struct {
+	// HMACSecret verifies HS256 tokens when set
+	HMACSecret []byte
+	// RSAKeys verifies RS256 tokens by key ID, as loaded from a JWKS file
+	RSAKeys map[string]*rsa.PublicKey
+	// Issuer and Audience are checked against iss and aud when set
+	Issuer   string
+	Audience string
+	// RolesClaim names the claim holding roles, with dots for nested
+	// claims such as realm_access.roles
+	RolesClaim string
+	// RoleMapping maps claim values such as identity provider groups to
+	// roles. Values naming a role directly need no entry.
+	RoleMapping map[string]Role
+}
+
+// Authenticator verifies bearer tokens and maps their claims to roles
+type Authenticator struct {
+	config AuthConfig
+	parser *jwt.Parser
+}
+
+// NewAuthenticator returns an authenticator accepting HS256 tokens when a
+// secret is configured and RS256 tokens when RSA keys are
+func NewAuthenticator(config AuthConfig) (*Authenticator, error) {
+	var methods []string
+	if len(config.HMACSecret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if len(config.RSAKeys) > 0 {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("no HS256 secret or RS256 keys configured")
+	}
+	if config.RolesClaim == "" {
+		config.RolesClaim = "roles"
+	}
+	for value, role := range config.RoleMapping {
+		if _, ok := roleLevels[role]; !ok {
+			return nil, fmt.Errorf("claim value %q maps to unknown role %q", value, role)
+		}
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(tokenLeeway),
+	}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+
+	return &Authenticator{
+		config: config,
+		parser: jwt.NewParser(options...),
+	}, nil
+}
+
+// Authenticate verifies a raw token and returns its principal
+func (a *Authenticator) Authenticate(raw string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(raw, claims, a.key); err != nil {
+		return nil, err
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{
+		Subject: subject,
+		Roles:   a.roles(claims),
+	}, nil
+}
+
+// key picks the verification key for the token's algorithm and key ID
+func (a *Authenticator) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return a.config.HMACSecret, nil
+	case *jwt.SigningMethodRSA:
+		kid, _ := token.Header["kid"].(string)
+		if key, ok := a.config.RSAKeys[kid]; ok {
+			return key, nil
+		}
+		// A token without kid is accepted when there is only one key
+		if kid == "" && len(a.config.RSAKeys) == 1 {
+			for _, key := range a.config.RSAKeys {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+}
+
+// roles maps the values of the roles claim, a string, a space separated
+// string or an array of strings, to known roles. Unknown values are
+// ignored.
+func (a *Authenticator) roles(claims jwt.MapClaims) []Role {
+	var value interface{} = map[string]interface{}(claims)
+	for _, name := range strings.Split(a.config.RolesClaim, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+
+	var values []string
+	switch v := value.(type) {
+	case string:
+		values = strings.Fields(v)
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	var roles []Role
+	for _, v := range values {
+		if role, ok := a.config.RoleMapping[v]; ok {
+			roles = append(roles, role)
+		} else if _, ok := roleLevels[Role(v)]; ok {
+			roles = append(roles, Role(v))
+		}
+	}
+	return roles
+}
+
+// AuthMiddleware requires a valid bearer token or API key and stores the
+// caller in the request context. API keys are sent as X-API-Key or as a
+// bearer token starting with the API key prefix.
+func AuthMiddleware(auth *Authenticator, keys APIKeyVerifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get the expected auth token from environment variable
-		expectedToken := os.Getenv("AUTH_TOKEN")
-		if expectedToken == "" {
-			expectedToken = "admin-secret-token-2024" // Default token for development
+		credential := c.GetHeader("X-API-Key")
+		if credential == "" {
+			// Get the Authorization header
+			authHeader := c.GetHeader("Authorization")
+			if authHeader == "" {
+				unauthorized(c, gin.H{
+					"error": "Authorization header is required",
+				})
+				return
+			}
+
+			scheme, token, found := strings.Cut(authHeader, " ")
+			if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+				unauthorized(c, gin.H{
+					"error": "Authorization header must be a Bearer token",
+				})
+				return
+			}
+			credential = strings.TrimSpace(token)
 		}
 
-		// Get the Authorization header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Authorization header is required",
+		if c.GetHeader("X-API-Key") != "" || strings.HasPrefix(credential, services.APIKeyPrefix) {
+			key, err := keys.VerifyKey(c.Request.Context(), credential)
+			// Unknown, revoked and expired keys get the same answer so that a
+			// guessed secret does not reveal whether it ever existed
+			if errors.Is(err, services.ErrInvalidAPIKey) || errors.Is(err, services.ErrAPIKeyRevoked) || errors.Is(err, services.ErrAPIKeyExpired) {
+				unauthorized(c, gin.H{
+					"error": "Invalid API key",
+				})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "Failed to verify API key",
+					"details": err.Error(),
+				})
+				c.Abort()
+				return
+			}
+
+			c.Set(principalKey, &Principal{
+				Subject:    key.Owner,
+				Scopes:     key.Scopes,
+				APIKeyID:   key.ID.Hex(),
+				APIKeyName: key.Name,
+			})
+			c.Set(APIKeyIDKey, key.ID.Hex())
+			c.Next()
+			return
+		}
+
+		principal, err := auth.Authenticate(credential)
+		if err != nil {
+			unauthorized(c, gin.H{
+				"error":   "Invalid authorization token",
+				"details": err.Error(),
 			})
-			c.Abort()
 			return
 		}
 
-		// Check if the token matches
-		if authHeader != expectedToken {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid authorization token",
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// Require lets tokens holding role, or a role above it, and API keys
+// granted scope through. Without a scope API keys are refused. It must run
+// after AuthMiddleware.
+func Require(role Role, scope string) gin.HandlerFunc {
+	requirement := fmt.Sprintf("the %s role", role)
+	if scope != "" {
+		requirement += fmt.Sprintf(" or the %s scope", scope)
+	}
+
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			unauthorized(c, gin.H{
+				"error": "Authentication is required",
+			})
+			return
+		}
+
+		if !principal.HasRole(role) && (scope == "" || !principal.HasScope(scope)) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Insufficient permissions",
+				"details": "this endpoint requires " + requirement,
 			})
 			c.Abort()
 			return
 		}
 
-		// Continue to the next handler
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// RequireRole only lets tokens holding role, or a role above it, through
+func RequireRole(role Role) gin.HandlerFunc {
+	return Require(role, "")
+}
+
+// CurrentPrincipal returns the caller authenticated by AuthMiddleware
+func CurrentPrincipal(c *gin.Context) (*Principal, bool) {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return nil, false
+	}
+	principal, ok := value.(*Principal)
+	return principal, ok
+}
+
+func unauthorized(c *gin.Context, body gin.H) {
+	c.Header("WWW-Authenticate", "Bearer")
+	c.JSON(http.StatusUnauthorized, body)
+	c.Abort()
+}
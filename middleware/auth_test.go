@@ -0,0 +1,250 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"admin_statistics_api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testSecret = []byte("test-secret")
+
+// signed returns an HS256 token for claims, with sub and exp filled in
+// unless given
+func signed(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	if _, ok := claims["sub"]; !ok {
+		claims["sub"] = "analyst@example.com"
+	}
+	if _, ok := claims["exp"]; !ok {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+	}
+	raw, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(testSecret)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return raw
+}
+
+func TestAuthenticatorAlgorithms(t *testing.T) {
+	auth, err := NewAuthenticator(AuthConfig{HMACSecret: testSecret})
+	if err != nil {
+		t.Fatalf("NewAuthenticator: %v", err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	claims := func() jwt.MapClaims {
+		return jwt.MapClaims{"sub": "analyst@example.com", "exp": time.Now().Add(time.Hour).Unix()}
+	}
+	rs256, _ := jwt.NewWithClaims(jwt.SigningMethodRS256, claims()).SignedString(rsaKey)
+	none, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	otherSecret, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims()).SignedString([]byte("other-secret"))
+	hs512, _ := jwt.NewWithClaims(jwt.SigningMethodHS512, claims()).SignedString(testSecret)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "HS256 with the secret", token: signed(t, jwt.MapClaims{})},
+		{name: "HS256 with another secret", token: otherSecret, wantErr: true},
+		{name: "HS512 is not configured", token: hs512, wantErr: true},
+		{name: "RS256 without RSA keys", token: rs256, wantErr: true},
+		{name: "alg none", token: none, wantErr: true},
+		{name: "expired", token: signed(t, jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}), wantErr: true},
+		{name: "expired within the leeway", token: signed(t, jwt.MapClaims{"exp": time.Now().Add(-10 * time.Second).Unix()})},
+		{name: "no exp", token: signed(t, jwt.MapClaims{"exp": nil}), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := auth.Authenticate(tt.token)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got principal %+v, want an error", principal)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if principal.Subject != "analyst@example.com" {
+				t.Errorf("got subject %q, want analyst@example.com", principal.Subject)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorRSAKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	auth, err := NewAuthenticator(AuthConfig{RSAKeys: map[string]*rsa.PublicKey{"k1": &key.PublicKey}})
+	if err != nil {
+		t.Fatalf("NewAuthenticator: %v", err)
+	}
+
+	token := func(kid string) string {
+		unsigned := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "svc", "exp": time.Now().Add(time.Hour).Unix()})
+		if kid != "" {
+			unsigned.Header["kid"] = kid
+		}
+		raw, _ := unsigned.SignedString(key)
+		return raw
+	}
+
+	if _, err := auth.Authenticate(token("k1")); err != nil {
+		t.Errorf("known kid: %v", err)
+	}
+	if _, err := auth.Authenticate(token("")); err != nil {
+		t.Errorf("no kid with a single key: %v", err)
+	}
+	if _, err := auth.Authenticate(token("k2")); err == nil {
+		t.Error("unknown kid: got no error")
+	}
+	// HS256 is refused when only RSA keys are configured
+	if _, err := auth.Authenticate(signed(t, jwt.MapClaims{})); err == nil {
+		t.Error("HS256 without a secret: got no error")
+	}
+}
+
+func TestAuthenticatorIssuerAndAudience(t *testing.T) {
+	auth, err := NewAuthenticator(AuthConfig{HMACSecret: testSecret, Issuer: "https://idp.example.com", Audience: "admin-statistics"})
+	if err != nil {
+		t.Fatalf("NewAuthenticator: %v", err)
+	}
+
+	if _, err := auth.Authenticate(signed(t, jwt.MapClaims{"iss": "https://idp.example.com", "aud": "admin-statistics"})); err != nil {
+		t.Errorf("matching iss and aud: %v", err)
+	}
+	if _, err := auth.Authenticate(signed(t, jwt.MapClaims{"iss": "https://evil.example.com", "aud": "admin-statistics"})); err == nil {
+		t.Error("other issuer: got no error")
+	}
+	if _, err := auth.Authenticate(signed(t, jwt.MapClaims{"iss": "https://idp.example.com"})); err == nil {
+		t.Error("no audience: got no error")
+	}
+}
+
+func TestAuthenticatorRoles(t *testing.T) {
+	tests := []struct {
+		name   string
+		config AuthConfig
+		claims jwt.MapClaims
+		want   []Role
+	}{
+		{
+			name:   "array of roles",
+			claims: jwt.MapClaims{"roles": []interface{}{"viewer", "analyst"}},
+			want:   []Role{RoleViewer, RoleAnalyst},
+		},
+		{
+			name:   "space separated string",
+			claims: jwt.MapClaims{"roles": "viewer admin"},
+			want:   []Role{RoleViewer, RoleAdmin},
+		},
+		{
+			name:   "unknown values are ignored",
+			claims: jwt.MapClaims{"roles": []interface{}{"superuser", "analyst", 42}},
+			want:   []Role{RoleAnalyst},
+		},
+		{
+			name:   "nested claim",
+			config: AuthConfig{RolesClaim: "realm_access.roles"},
+			claims: jwt.MapClaims{"realm_access": map[string]interface{}{"roles": []interface{}{"admin"}}},
+			want:   []Role{RoleAdmin},
+		},
+		{
+			name:   "nested claim that is not an object",
+			config: AuthConfig{RolesClaim: "realm_access.roles"},
+			claims: jwt.MapClaims{"realm_access": "admin"},
+		},
+		{
+			name:   "mapped groups",
+			config: AuthConfig{RolesClaim: "groups", RoleMapping: map[string]Role{"finance": RoleAnalyst, "ops-leads": RoleAdmin}},
+			claims: jwt.MapClaims{"groups": []interface{}{"finance", "marketing", "viewer"}},
+			want:   []Role{RoleAnalyst, RoleViewer},
+		},
+		{
+			name:   "no roles claim",
+			claims: jwt.MapClaims{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.HMACSecret = testSecret
+			auth, err := NewAuthenticator(tt.config)
+			if err != nil {
+				t.Fatalf("NewAuthenticator: %v", err)
+			}
+
+			principal, err := auth.Authenticate(signed(t, tt.claims))
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if !reflect.DeepEqual(principal.Roles, tt.want) {
+				t.Errorf("got roles %v, want %v", principal.Roles, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAuthenticatorRejectsBadConfig(t *testing.T) {
+	if _, err := NewAuthenticator(AuthConfig{}); err == nil {
+		t.Error("no keys: got no error")
+	}
+	if _, err := NewAuthenticator(AuthConfig{HMACSecret: testSecret, RoleMapping: map[string]Role{"finance": "owner"}}); err == nil {
+		t.Error("mapping to an unknown role: got no error")
+	}
+}
+
+func TestRequire(t *testing.T) {
+	tests := []struct {
+		name       string
+		principal  *Principal
+		role       Role
+		scope      string
+		wantStatus int
+	}{
+		{name: "unauthenticated", role: RoleViewer, wantStatus: http.StatusUnauthorized},
+		{name: "same role", principal: &Principal{Roles: []Role{RoleAnalyst}}, role: RoleAnalyst, wantStatus: http.StatusOK},
+		{name: "higher role", principal: &Principal{Roles: []Role{RoleAdmin}}, role: RoleViewer, wantStatus: http.StatusOK},
+		{name: "lower role", principal: &Principal{Roles: []Role{RoleViewer}}, role: RoleAnalyst, wantStatus: http.StatusForbidden},
+		{name: "no roles", principal: &Principal{}, role: RoleViewer, wantStatus: http.StatusForbidden},
+		{name: "API key with the scope", principal: &Principal{Scopes: []string{models.ScopeStatsRead}}, role: RoleViewer, scope: models.ScopeStatsRead, wantStatus: http.StatusOK},
+		{name: "API key without the scope", principal: &Principal{Scopes: []string{models.ScopeTransactionsRead}}, role: RoleViewer, scope: models.ScopeStatsRead, wantStatus: http.StatusForbidden},
+		{name: "API key on a role-only route", principal: &Principal{Scopes: []string{models.ScopeStatsRead}}, role: RoleViewer, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/", func(c *gin.Context) {
+				if tt.principal != nil {
+					c.Set(principalKey, tt.principal)
+				}
+			}, Require(tt.role, tt.scope), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("got %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
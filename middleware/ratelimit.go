@@ -0,0 +1,226 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitPolicy is a token bucket: each client holds up to Capacity
+// points, refilled at RefillPerSecond, and every request spends the cost
+// of its route
+type RateLimitPolicy struct {
+	Capacity        int
+	RefillPerSecond float64
+}
+
+// RateLimitResult is the state of a client's bucket after a request
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until the denied request would be allowed
+	RetryAfter time.Duration
+}
+
+// RateLimiter spends cost points from the bucket of key. A cost of 0 only
+// checks that the bucket holds at least one point.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, cost int) (RateLimitResult, error)
+}
+
+// Result derives the result of spending cost from a bucket holding tokens
+// after the attempt
+func (p RateLimitPolicy) Result(tokens float64, cost int, allowed bool) RateLimitResult {
+	result := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     p.Capacity,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     p.refillTime(float64(p.Capacity) - tokens),
+	}
+	if !allowed {
+		result.RetryAfter = p.refillTime(math.Max(float64(cost), 1) - tokens)
+	}
+	return result
+}
+
+func (p RateLimitPolicy) refillTime(points float64) time.Duration {
+	if points <= 0 {
+		return 0
+	}
+	return time.Duration(points / p.RefillPerSecond * float64(time.Second))
+}
+
+// ClampCost keeps a route's cost within the bucket so it can ever pass
+func (p RateLimitPolicy) ClampCost(cost int) int {
+	if cost > p.Capacity {
+		return p.Capacity
+	}
+	if cost < 0 {
+		return 0
+	}
+	return cost
+}
+
+type memoryBucket struct {
+	tokens float64
+	at     time.Time
+}
+
+// MemoryRateLimiter keeps buckets in process. It is used when Redis is
+// unavailable, so limits then apply per instance.
+type MemoryRateLimiter struct {
+	policy RateLimitPolicy
+
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimiter(policy RateLimitPolicy) *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		policy:    policy,
+		buckets:   make(map[string]*memoryBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *MemoryRateLimiter) Allow(ctx context.Context, key string, cost int) (RateLimitResult, error) {
+	cost = l.policy.ClampCost(cost)
+	capacity := float64(l.policy.Capacity)
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: capacity, at: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.at).Seconds()*l.policy.RefillPerSecond)
+	bucket.at = now
+
+	allowed := bucket.tokens >= math.Max(float64(cost), 1)
+	if allowed {
+		bucket.tokens -= float64(cost)
+	}
+	return l.policy.Result(bucket.tokens, cost, allowed), nil
+}
+
+// sweep drops buckets that have refilled completely, at most once a
+// minute, so idle clients do not accumulate
+func (l *MemoryRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.at) >= l.policy.refillTime(float64(l.policy.Capacity)-bucket.tokens) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RateLimit spends cost points of the caller's bucket, answering 429 with
+// Retry-After once it is empty. Callers are told apart by API key, token
+// subject or, on public routes, client IP, so it must run after
+// AuthMiddleware on protected routes. Responses carry RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset. When the limiter fails the
+// request is let through, and a nil limiter disables rate limiting.
+func RateLimit(limiter RateLimiter, cost int) gin.HandlerFunc {
+	if limiter == nil {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		result, err := limiter.Allow(c.Request.Context(), rateLimitKey(c), cost)
+		if err != nil {
+			log.Printf("Rate limiter unavailable, allowing request: %v", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			rateLimited(c, result)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// LimitFailedAuth charges requests that end in 401 or 403 to the bucket of
+// the client IP and refuses further requests from that IP, before their
+// credentials are checked, once the bucket is empty. It must run before
+// AuthMiddleware so that guessed tokens and API keys, which RateLimit never
+// sees, are limited too. A nil limiter disables it.
+func LimitFailedAuth(limiter RateLimiter, cost int) gin.HandlerFunc {
+	if limiter == nil {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		key := "ip:" + c.ClientIP()
+		result, err := limiter.Allow(c.Request.Context(), key, 0)
+		if err != nil {
+			log.Printf("Rate limiter unavailable, allowing request: %v", err)
+		} else if !result.Allowed {
+			rateLimited(c, result)
+			return
+		}
+
+		c.Next()
+
+		if status := c.Writer.Status(); status == http.StatusUnauthorized || status == http.StatusForbidden {
+			if _, err := limiter.Allow(c.Request.Context(), key, cost); err != nil {
+				log.Printf("Failed to charge a rejected request to %s: %v", key, err)
+			}
+		}
+	}
+}
+
+// rateLimited answers 429 with the time until the request would pass
+func rateLimited(c *gin.Context, result RateLimitResult) {
+	retryAfter := ceilSeconds(result.RetryAfter)
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":   "Rate limit exceeded",
+		"details": "retry after " + strconv.Itoa(retryAfter) + " seconds",
+	})
+	c.Abort()
+}
+
+// rateLimitKey identifies the caller whose bucket a request spends
+func rateLimitKey(c *gin.Context) string {
+	if principal, ok := CurrentPrincipal(c); ok {
+		if principal.APIKeyID != "" {
+			return "key:" + principal.APIKeyID
+		}
+		return "sub:" + principal.Subject
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
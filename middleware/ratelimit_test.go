@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMemoryRateLimiterAllow(t *testing.T) {
+	ctx := context.Background()
+	policy := RateLimitPolicy{Capacity: 10, RefillPerSecond: 2}
+
+	tests := []struct {
+		name string
+		// costs are spent in order before the checked request
+		costs         []int
+		cost          int
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+	}{
+		{name: "full bucket", cost: 1, wantAllowed: true, wantRemaining: 9},
+		{name: "whole burst", cost: 10, wantAllowed: true, wantRemaining: 0},
+		{name: "cost above capacity is clamped", cost: 50, wantAllowed: true, wantRemaining: 0},
+		{name: "empty bucket", costs: []int{10}, cost: 1, wantAllowed: false, wantRemaining: 0, wantRetry: 500 * time.Millisecond},
+		{name: "too few points left", costs: []int{8}, cost: 5, wantAllowed: false, wantRemaining: 2, wantRetry: 1500 * time.Millisecond},
+		{name: "check only spends nothing", costs: []int{9}, cost: 0, wantAllowed: true, wantRemaining: 1},
+		{name: "check only on an empty bucket", costs: []int{10}, cost: 0, wantAllowed: false, wantRemaining: 0, wantRetry: 500 * time.Millisecond},
+		{name: "negative cost is a check", costs: []int{4}, cost: -3, wantAllowed: true, wantRemaining: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewMemoryRateLimiter(policy)
+			for _, cost := range tt.costs {
+				limiter.Allow(ctx, "client", cost)
+			}
+
+			result, err := limiter.Allow(ctx, "client", tt.cost)
+			if err != nil {
+				t.Fatalf("Allow: %v", err)
+			}
+			if result.Allowed != tt.wantAllowed || result.Remaining != tt.wantRemaining || result.Limit != policy.Capacity {
+				t.Errorf("got allowed %v remaining %d limit %d, want %v %d %d",
+					result.Allowed, result.Remaining, result.Limit, tt.wantAllowed, tt.wantRemaining, policy.Capacity)
+			}
+			// The bucket refills a little between calls
+			if result.RetryAfter > tt.wantRetry || result.RetryAfter < tt.wantRetry-10*time.Millisecond {
+				t.Errorf("got retry after %v, want %v", result.RetryAfter, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimiterRefill(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewMemoryRateLimiter(RateLimitPolicy{Capacity: 10, RefillPerSecond: 2})
+
+	limiter.Allow(ctx, "client", 10)
+	if result, _ := limiter.Allow(ctx, "other", 1); !result.Allowed || result.Remaining != 9 {
+		t.Fatalf("got %+v for another client, want its own full bucket", result)
+	}
+
+	// Three seconds refill six points
+	limiter.buckets["client"].at = time.Now().Add(-3 * time.Second)
+	result, _ := limiter.Allow(ctx, "client", 1)
+	if !result.Allowed || result.Remaining != 5 {
+		t.Errorf("got allowed %v remaining %d, want true 5", result.Allowed, result.Remaining)
+	}
+
+	// The bucket never holds more than its capacity
+	limiter.buckets["client"].at = time.Now().Add(-time.Hour)
+	result, _ = limiter.Allow(ctx, "client", 1)
+	if result.Remaining != 9 || result.Reset > 500*time.Millisecond {
+		t.Errorf("got remaining %d reset %v, want 9 and at most 500ms", result.Remaining, result.Reset)
+	}
+}
+
+// serveLimited sends a request through LimitFailedAuth to a handler
+// answering status, and returns the response
+func serveLimited(handler gin.HandlerFunc, status int) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", handler, func(c *gin.Context) {
+		c.Status(status)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "203.0.113.7:40000"
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestLimitFailedAuth(t *testing.T) {
+	limiter := NewMemoryRateLimiter(RateLimitPolicy{Capacity: 20, RefillPerSecond: 0.01})
+	handler := LimitFailedAuth(limiter, 10)
+
+	// Successful requests are not charged
+	for i := 0; i < 5; i++ {
+		if w := serveLimited(handler, http.StatusOK); w.Code != http.StatusOK {
+			t.Fatalf("request %d: got %d, want 200", i, w.Code)
+		}
+	}
+
+	// Two rejections empty the bucket, after which the IP is refused
+	// before its credentials are looked at
+	for _, want := range []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests, http.StatusTooManyRequests} {
+		status := http.StatusUnauthorized
+		if want == http.StatusForbidden {
+			status = http.StatusForbidden
+		}
+		w := serveLimited(handler, status)
+		if w.Code != want {
+			t.Fatalf("got %d, want %d", w.Code, want)
+		}
+		if want == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Error("got no Retry-After header on a 429")
+		}
+	}
+
+	if w := serveLimited(LimitFailedAuth(nil, 10), http.StatusUnauthorized); w.Code != http.StatusUnauthorized {
+		t.Errorf("got %d with a nil limiter, want the handler's 401", w.Code)
+	}
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Scopes an API key can be granted
+const (
+	ScopeStatsRead         = "stats:read"
+	ScopeUsersRead         = "users:read"
+	ScopeTransactionsRead  = "transactions:read"
+	ScopeTransactionsWrite = "transactions:write"
+)
+
+// ValidScopes lists every scope an API key can be granted
+var ValidScopes = []string{
+	ScopeStatsRead,
+	ScopeUsersRead,
+	ScopeTransactionsRead,
+	ScopeTransactionsWrite,
+}
+
+// IsValidScope reports whether scope is one of ValidScopes
+func IsValidScope(scope string) bool {
+	for _, valid := range ValidScopes {
+		if valid == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey is a managed API key. Only the SHA-256 hash of the secret is
+// stored; Prefix keeps its first characters so a key can be recognised.
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name       string             `bson:"name" json:"name"`
+	Owner      string             `bson:"owner" json:"owner"`
+	Prefix     string             `bson:"prefix" json:"prefix"`
+	Hash       string             `bson:"hash" json:"-"`
+	Scopes     []string           `bson:"scopes" json:"scopes"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt  *time.Time         `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time         `bson:"lastUsedAt,omitempty" json:"lastUsedAt,omitempty"`
+	RotatedAt  *time.Time         `bson:"rotatedAt,omitempty" json:"rotatedAt,omitempty"`
+	RevokedAt  *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
+
+// APIKeyRequest is the payload creating an API key. Without expiresAt the
+// key does not expire. The scope tag accepts ValidScopes.
+type APIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=128"`
+	Owner     string     `json:"owner" validate:"required,max=128"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,scope"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// APIKeySecret is returned when a key is created or rotated and is the
+// only time the secret is shown
+type APIKeySecret struct {
+	Key    APIKey `json:"key"`
+	Secret string `json:"secret"`
+}
@@ -0,0 +1,31 @@
+package models
+
+// ValueDelta compares a value between a range and its comparison window.
+// ChangePercent is relative to the magnitude of Previous and is nil when
+// Previous is zero.
+type ValueDelta struct {
+	Current       Decimal  `json:"current"`
+	Previous      Decimal  `json:"previous"`
+	Change        Decimal  `json:"change"`
+	ChangePercent *float64 `json:"changePercent"`
+}
+
+// CurrencyDelta compares one currency's totals in its own units, in USD
+// and in the reporting currency
+type CurrencyDelta struct {
+	Currency string     `json:"currency"`
+	Amount   ValueDelta `json:"amount"`
+	USDValue ValueDelta `json:"usdValue"`
+	Value    ValueDelta `json:"value"`
+}
+
+// PeriodComparison holds the deltas between a range and its comparison
+// window, per currency and for the total across currencies in USD and in
+// ReportCurrency. Reported values take each window's USD totals at the rate
+// in effect at its end.
+type PeriodComparison struct {
+	ByCurrency     []CurrencyDelta `json:"byCurrency"`
+	TotalUSD       ValueDelta      `json:"totalUSD"`
+	Total          ValueDelta      `json:"total"`
+	ReportCurrency string          `json:"reportCurrency"`
+}
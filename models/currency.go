@@ -0,0 +1,19 @@
+package models
+
+// Currency types
+const (
+	CurrencyTypeCrypto = "crypto"
+	CurrencyTypeFiat   = "fiat"
+)
+
+// Currency is an entry of the currencies collection. Decimals is the
+// precision amounts in the currency are presented with. Disabled
+// currencies are still reported on but no longer accepted for new
+// transactions.
+type Currency struct {
+	Code     string `bson:"code" json:"code"`
+	Name     string `bson:"name" json:"name"`
+	Decimals int32  `bson:"decimals" json:"decimals"`
+	Type     string `bson:"type" json:"type"`
+	Enabled  bool   `bson:"enabled" json:"enabled"`
+}
@@ -0,0 +1,226 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Decimal is an exact decimal amount. Sums are carried exactly from the
+// database to the response; rounding only happens once a handler presents
+// the value with the precision of its currency.
+//
+// It is written to BSON as a Decimal128 and to JSON as a string, or as a
+// number when presented for clients that asked for floats.
+type Decimal struct {
+	value decimal.Decimal
+
+	// places and asFloat are set by Present and only affect encoding
+	presented bool
+	places    int32
+	asFloat   bool
+}
+
+// DecimalFromInt returns an integer as a decimal
+func DecimalFromInt(value int64) Decimal {
+	return Decimal{value: decimal.NewFromInt(value)}
+}
+
+// DecimalFromFloat returns the shortest decimal that represents a float,
+// e.g. 0.1 rather than 0.1000000000000000055511151231257827
+func DecimalFromFloat(value float64) Decimal {
+	return Decimal{value: decimal.NewFromFloat(value)}
+}
+
+// DecimalFrom128 converts a stored Decimal128 exactly. NaN, infinities and
+// malformed values become zero.
+func DecimalFrom128(value primitive.Decimal128) Decimal {
+	parsed, err := decimal.NewFromString(value.String())
+	if err != nil {
+		return Decimal{}
+	}
+	return Decimal{value: parsed}
+}
+
+// ParseDecimal parses a decimal string such as "12.34" or "1.5E-3"
+func ParseDecimal(value string) (Decimal, error) {
+	parsed, err := decimal.NewFromString(value)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("%q is not a decimal number", value)
+	}
+	return Decimal{value: parsed}, nil
+}
+
+func (d Decimal) Add(other Decimal) Decimal {
+	return Decimal{value: d.value.Add(other.value)}
+}
+
+func (d Decimal) Sub(other Decimal) Decimal {
+	return Decimal{value: d.value.Sub(other.value)}
+}
+
+// MulFloat multiplies by a float factor such as an exchange rate, taken as
+// the shortest decimal that represents it
+func (d Decimal) MulFloat(factor float64) Decimal {
+	return Decimal{value: d.value.Mul(decimal.NewFromFloat(factor))}
+}
+
+// DivFloat divides by a float divisor such as an exchange rate. Inexact
+// quotients keep 16 decimal places.
+func (d Decimal) DivFloat(divisor float64) Decimal {
+	return Decimal{value: d.value.Div(decimal.NewFromFloat(divisor))}
+}
+
+// DivInt divides by a count. Inexact quotients keep 16 decimal places.
+func (d Decimal) DivInt(divisor int64) Decimal {
+	return Decimal{value: d.value.Div(decimal.NewFromInt(divisor))}
+}
+
+// Ratio returns d / other as a float, for multipliers and percentages
+func (d Decimal) Ratio(other Decimal) float64 {
+	ratio, _ := d.value.Div(other.value).Float64()
+	return ratio
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{value: d.value.Neg()}
+}
+
+func (d Decimal) Abs() Decimal {
+	return Decimal{value: d.value.Abs()}
+}
+
+func (d Decimal) IsZero() bool {
+	return d.value.IsZero()
+}
+
+func (d Decimal) IsNegative() bool {
+	return d.value.IsNegative()
+}
+
+func (d Decimal) IsPositive() bool {
+	return d.value.IsPositive()
+}
+
+// Round rounds half away from zero to the given number of places
+func (d Decimal) Round(places int32) Decimal {
+	return Decimal{value: d.value.Round(places)}
+}
+
+// Cmp returns -1, 0 or +1 as d is less than, equal to or greater than other
+func (d Decimal) Cmp(other Decimal) int {
+	return d.value.Cmp(other.value)
+}
+
+// Float64 returns the nearest float to the exact value
+func (d Decimal) Float64() float64 {
+	f, _ := d.value.Float64()
+	return f
+}
+
+// String returns the exact value in plain decimal notation
+func (d Decimal) String() string {
+	return d.value.String()
+}
+
+// Present rounds the value to places for encoding, written as a string
+// with exactly that many decimals or, with asFloat, as a JSON number
+func (d Decimal) Present(places int32, asFloat bool) Decimal {
+	return Decimal{
+		value:     d.value.Round(places),
+		presented: true,
+		places:    places,
+		asFloat:   asFloat,
+	}
+}
+
+// Text is the value as encoded in JSON strings and CSV cells: fixed to the
+// presented places, or exact when the value was never presented
+func (d Decimal) Text() string {
+	if d.presented {
+		return d.value.StringFixed(d.places)
+	}
+	return d.value.String()
+}
+
+// Decimal128 converts the value for storage. Values with more significant
+// digits than a Decimal128 holds are rounded to fit.
+func (d Decimal) Decimal128() (primitive.Decimal128, error) {
+	value, err := primitive.ParseDecimal128(d.value.String())
+	if err == nil {
+		return value, nil
+	}
+	return primitive.ParseDecimal128(d.value.Round(16).String())
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	if d.asFloat {
+		return []byte(d.Text()), nil
+	}
+	return []byte(strconv.Quote(d.Text())), nil
+}
+
+// UnmarshalJSON accepts decimal strings and JSON numbers
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*d = Decimal{}
+		return nil
+	}
+
+	var text string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	} else {
+		text = string(data)
+	}
+
+	parsed, err := ParseDecimal(text)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d Decimal) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	value, err := d.Decimal128()
+	if err != nil {
+		return 0, nil, err
+	}
+	return bson.MarshalValue(value)
+}
+
+// UnmarshalBSONValue accepts Decimal128, the numeric types aggregations
+// may return for empty or integral sums, and decimal strings
+func (d *Decimal) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	raw := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.Decimal128:
+		*d = DecimalFrom128(raw.Decimal128())
+	case bsontype.Double:
+		*d = DecimalFromFloat(raw.Double())
+	case bsontype.Int32:
+		*d = DecimalFromInt(int64(raw.Int32()))
+	case bsontype.Int64:
+		*d = DecimalFromInt(raw.Int64())
+	case bsontype.String:
+		parsed, err := ParseDecimal(raw.StringValue())
+		if err != nil {
+			return err
+		}
+		*d = parsed
+	case bsontype.Null, bsontype.Undefined:
+		*d = Decimal{}
+	default:
+		return fmt.Errorf("cannot decode BSON %s into a decimal", t)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// ExchangeRate is the price of one unit of Base in Quote. It is in effect
+// from EffectiveAt until the next rate of the same pair takes effect.
+type ExchangeRate struct {
+	Base        string    `bson:"base" json:"base" validate:"required,max=16"`
+	Quote       string    `bson:"quote" json:"quote" validate:"required,max=16"`
+	Rate        float64   `bson:"rate" json:"rate" validate:"gt=0"`
+	EffectiveAt time.Time `bson:"effectiveAt" json:"effectiveAt" validate:"required"`
+	Source      string    `bson:"source,omitempty" json:"source,omitempty" validate:"max=64"`
+}
+
+// RateSeries summarises the stored rates of one currency pair
+type RateSeries struct {
+	Base       string    `bson:"base" json:"base"`
+	Quote      string    `bson:"quote" json:"quote"`
+	Count      int       `bson:"count" json:"count"`
+	FirstAt    time.Time `bson:"firstAt" json:"firstAt"`
+	LastAt     time.Time `bson:"lastAt" json:"lastAt"`
+	LatestRate float64   `bson:"latestRate" json:"latestRate"`
+}
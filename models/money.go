@@ -0,0 +1,8 @@
+package models
+
+// Money is a value in a named currency, used for results reported in a
+// currency other than USD
+type Money struct {
+	Value    Decimal `json:"value"`
+	Currency string  `json:"currency"`
+}
@@ -0,0 +1,20 @@
+package models
+
+// RoundDetail describes the wager and payouts of a single round. Amounts
+// are in the round's currency; NetResult is from the player's point of
+// view (payouts minus wager), so a round adds -NetResult to GGR.
+type RoundDetail struct {
+	RoundID        string        `json:"roundId"`
+	UserID         string        `json:"userId,omitempty"`
+	Currency       string        `json:"currency,omitempty"`
+	Wager          *Transaction  `json:"wager"`
+	Payouts        []Transaction `json:"payouts"`
+	WagerAmount    Decimal       `json:"wagerAmount"`
+	PayoutAmount   Decimal       `json:"payoutAmount"`
+	Multiplier     float64       `json:"multiplier"`
+	ElapsedSeconds *float64      `json:"elapsedSeconds,omitempty"`
+	NetResult      Decimal       `json:"netResult"`
+	NetResultUSD   Decimal       `json:"netResultUSD"`
+	Settled        bool          `json:"settled"`
+	Issues         []string      `json:"issues,omitempty"`
+}
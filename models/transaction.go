@@ -1,39 +1,165 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Transaction struct {
-	ID        primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
-	CreatedAt time.Time              `bson:"createdAt" json:"createdAt"`
-	UserID    primitive.ObjectID     `bson:"userId" json:"userId"`
-	RoundID   string                 `bson:"roundId" json:"roundId"`
-	Type      string                 `bson:"type" json:"type" validate:"required,oneof=Wager Payout"`
-	Amount    primitive.Decimal128   `bson:"amount" json:"amount"`
-	Currency  string                 `bson:"currency" json:"currency" validate:"required,oneof=ETH BTC USDT"`
-	USDAmount primitive.Decimal128   `bson:"usdAmount" json:"usdAmount"`
+	ID        primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	CreatedAt time.Time            `bson:"createdAt" json:"createdAt" validate:"required"`
+	UserID    primitive.ObjectID   `bson:"userId" json:"userId" validate:"required"`
+	RoundID   string               `bson:"roundId" json:"roundId" validate:"required,max=128"`
+	Type      string               `bson:"type" json:"type" validate:"required,oneof=Wager Payout"`
+	Amount    primitive.Decimal128 `bson:"amount" json:"amount"`
+	Currency  string               `bson:"currency" json:"currency" validate:"required,currency"`
+	USDAmount primitive.Decimal128 `bson:"usdAmount" json:"usdAmount"`
+
+	// IdempotencyKey is supplied by the client so retried submissions are
+	// stored only once
+	IdempotencyKey string `bson:"idempotencyKey,omitempty" json:"idempotencyKey,omitempty" validate:"max=128"`
+	// RoundSlot is empty for the single Wager and Payout of a round and is
+	// only set on additional payouts that were explicitly allowed
+	RoundSlot string `bson:"roundSlot,omitempty" json:"roundSlot,omitempty"`
 }
 
+// TransactionRequest is the ingestion payload for a transaction. Amounts
+// may be sent as JSON numbers or decimal strings; usdAmount and createdAt
+// are optional and default to the converted amount and the current time.
+// Its tags are checked before the amount is converted.
+type TransactionRequest struct {
+	UserID    string      `json:"userId"`
+	RoundID   string      `json:"roundId" validate:"required,max=128"`
+	Type      string      `json:"type" validate:"required,oneof=Wager Payout"`
+	Amount    json.Number `json:"amount"`
+	Currency  string      `json:"currency" validate:"required,currency"`
+	USDAmount json.Number `json:"usdAmount,omitempty"`
+	CreatedAt *time.Time  `json:"createdAt,omitempty"`
+
+	IdempotencyKey       string `json:"idempotencyKey,omitempty" validate:"max=128"`
+	AllowMultiplePayouts bool   `json:"allowMultiplePayouts,omitempty"`
+}
+
+// GrossGamingRevenue is the GGR of one currency. Value is USDValue in the
+// reporting currency. Revaluation is only set when the request asked for
+// a valuation other than the booked one.
 type GrossGamingRevenue struct {
-	Currency string  `json:"currency"`
-	Amount   float64 `json:"amount"`
-	USDValue float64 `json:"usdValue"`
+	Currency    string       `json:"currency"`
+	Amount      Decimal      `json:"amount"`
+	USDValue    Decimal      `json:"usdValue"`
+	Value       Money        `bson:"-" json:"value"`
+	Revaluation *Revaluation `bson:"-" json:"revaluation,omitempty"`
 }
 
 type DailyWagerVolume struct {
-	Date     string  `json:"date"`
-	Currency string  `json:"currency"`
-	Amount   float64 `json:"amount"`
-	USDValue float64 `json:"usdValue"`
+	Date        string       `json:"date"`
+	Currency    string       `json:"currency"`
+	Amount      Decimal      `json:"amount"`
+	USDValue    Decimal      `json:"usdValue"`
+	Value       Money        `bson:"-" json:"value"`
+	Revaluation *Revaluation `bson:"-" json:"revaluation,omitempty"`
 }
 
+// WagerVolume is the wager total of one currency in a time bucket. Bucket
+// is the start of the bucket.
+type WagerVolume struct {
+	Bucket      time.Time    `json:"bucket"`
+	Currency    string       `json:"currency"`
+	Amount      Decimal      `json:"amount"`
+	USDValue    Decimal      `json:"usdValue"`
+	Value       Money        `bson:"-" json:"value"`
+	Revaluation *Revaluation `bson:"-" json:"revaluation,omitempty"`
+}
+
+// UserWagerPercentile ranks a user by total wagered USD. TiedUsers is the
+// number of other users with exactly the same total, who share the rank.
+// Value is TotalWagered in the reporting currency.
 type UserWagerPercentile struct {
-	UserID         string  `json:"userId"`
-	TotalWagered   float64 `json:"totalWagered"`
-	Percentile     float64 `json:"percentile"`
-	Rank           int     `json:"rank"`
-	TotalUsers     int     `json:"totalUsers"`
-}
\ No newline at end of file
+	UserID       string  `json:"userId"`
+	TotalWagered Decimal `json:"totalWagered"`
+	Value        Money   `json:"value"`
+	Percentile   float64 `json:"percentile"`
+	Rank         int     `json:"rank"`
+	TotalUsers   int     `json:"totalUsers"`
+	TiedUsers    int     `json:"tiedUsers"`
+}
+
+// DailyStat is a pre-aggregated rollup of one UTC day's transactions for a
+// single currency and transaction type, stored in the daily_stats collection
+type DailyStat struct {
+	Day       time.Time `bson:"day" json:"day"`
+	Currency  string    `bson:"currency" json:"currency"`
+	Type      string    `bson:"type" json:"type"`
+	Count     int64     `bson:"count" json:"count"`
+	Amount    Decimal   `bson:"amount" json:"amount"`
+	USDAmount Decimal   `bson:"usdAmount" json:"usdAmount"`
+	UserCount int64     `bson:"userCount" json:"userCount"`
+}
+
+// UserSummary describes a user's activity over a time range. NetGGR is the
+// revenue the user contributed (wagered minus paid out). The *Value fields
+// are their USD counterparts in the reporting currency.
+type UserSummary struct {
+	UserID            string                `json:"userId"`
+	Currencies        []string              `json:"currencies"`
+	RoundCount        int                   `json:"roundCount"`
+	TotalWageredUSD   Decimal               `json:"totalWageredUSD"`
+	TotalPaidOutUSD   Decimal               `json:"totalPaidOutUSD"`
+	NetGGRUSD         Decimal               `json:"netGGRUSD"`
+	TotalWageredValue Money                 `json:"totalWageredValue"`
+	TotalPaidOutValue Money                 `json:"totalPaidOutValue"`
+	NetGGRValue       Money                 `json:"netGGRValue"`
+	FirstActivity     time.Time             `json:"firstActivity"`
+	LastActivity      time.Time             `json:"lastActivity"`
+	ByCurrency        []UserCurrencySummary `json:"byCurrency"`
+}
+
+// UserCurrencySummary is a user's activity in a single currency. LargestWin
+// is the largest single payout.
+type UserCurrencySummary struct {
+	Currency        string    `bson:"currency" json:"currency"`
+	Wagered         Decimal   `bson:"wagered" json:"wagered"`
+	WageredUSD      Decimal   `bson:"wageredUSD" json:"wageredUSD"`
+	WageredValue    Money     `bson:"-" json:"wageredValue"`
+	PaidOut         Decimal   `bson:"paidOut" json:"paidOut"`
+	PaidOutUSD      Decimal   `bson:"paidOutUSD" json:"paidOutUSD"`
+	PaidOutValue    Money     `bson:"-" json:"paidOutValue"`
+	NetGGR          Decimal   `bson:"netGGR" json:"netGGR"`
+	NetGGRUSD       Decimal   `bson:"netGGRUSD" json:"netGGRUSD"`
+	NetGGRValue     Money     `bson:"-" json:"netGGRValue"`
+	RoundCount      int       `bson:"roundCount" json:"roundCount"`
+	WagerCount      int       `bson:"wagerCount" json:"wagerCount"`
+	AverageBet      Decimal   `bson:"averageBet" json:"averageBet"`
+	LargestWin      Decimal   `bson:"largestWin" json:"largestWin"`
+	LargestWinUSD   Decimal   `bson:"largestWinUSD" json:"largestWinUSD"`
+	LargestWinValue Money     `bson:"-" json:"largestWinValue"`
+	FirstActivity   time.Time `bson:"firstActivity" json:"firstActivity"`
+	LastActivity    time.Time `bson:"lastActivity" json:"lastActivity"`
+}
+
+// LeaderboardEntry is one ranked user. Value is the ranked metric; the
+// other totals are always included. NetLossUSD is wagered minus paid out,
+// i.e. what the user lost to the house. The *Value fields are the USD
+// totals in the reporting currency.
+type LeaderboardEntry struct {
+	Rank         int     `json:"rank"`
+	UserID       string  `json:"userId"`
+	Value        Decimal `json:"value"`
+	WageredUSD   Decimal `json:"wageredUSD"`
+	PaidOutUSD   Decimal `json:"paidOutUSD"`
+	NetLossUSD   Decimal `json:"netLossUSD"`
+	WageredValue Money   `json:"wageredValue"`
+	PaidOutValue Money   `json:"paidOutValue"`
+	NetLossValue Money   `json:"netLossValue"`
+	Rounds       int     `json:"rounds"`
+	Percentile   float64 `json:"percentile"`
+}
+
+type Leaderboard struct {
+	Metric     string             `json:"metric"`
+	Currency   string             `json:"currency,omitempty"`
+	TotalUsers int                `json:"totalUsers"`
+	Entries    []LeaderboardEntry `json:"entries"`
+}
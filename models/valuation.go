@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Revaluation restates a booked USD value at the rate of a valuation.
+// FXGainLoss is RevaluedUSD minus the booked value.
+type Revaluation struct {
+	Rate        float64 `json:"rate"`
+	RevaluedUSD Decimal `json:"revaluedUSD"`
+	FXGainLoss  Decimal `json:"fxGainLoss"`
+}
+
+// ValuationSummary describes the rates results were revalued at and the
+// booked and revalued USD totals across all of them
+type ValuationSummary struct {
+	Mode        string         `json:"mode"`
+	AsOf        time.Time      `json:"asOf"`
+	Rates       []ExchangeRate `json:"rates"`
+	BookedUSD   Decimal        `json:"bookedUSD"`
+	RevaluedUSD Decimal        `json:"revaluedUSD"`
+	FXGainLoss  Decimal        `json:"fxGainLoss"`
+}
@@ -9,9 +9,11 @@ import (
 
 	"admin_statistics_api/config"
 	"admin_statistics_api/models"
+	"admin_statistics_api/services"
 	"admin_statistics_api/utils"
 
 	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -40,12 +42,31 @@ func main() {
 	userIDs := generateUserIDs(MIN_USERS)
 	fmt.Printf("Generated %d unique user IDs\n", len(userIDs))
 
+	// Transactions use the enabled crypto currencies of the registry
+	currencies := config.LoadCurrencies(config.DB)
+
+	// Generate hourly exchange rates covering the transaction period
+	rates, codes, err := generateExchangeRates(currencies.EnabledCodes(models.CurrencyTypeCrypto), time.Now().AddDate(-1, 0, -1), time.Now())
+	if err != nil {
+		log.Fatal("Failed to generate exchange rates:", err)
+	}
+	if len(codes) == 0 {
+		log.Fatal("No enabled crypto currency to generate transactions in")
+	}
+
 	// Generate transactions
-	err := generateTransactions(userIDs, MIN_ROUNDS)
+	err = generateTransactions(userIDs, MIN_ROUNDS, rates, codes, currencies)
 	if err != nil {
 		log.Fatal("Failed to generate transactions:", err)
 	}
 
+	// Build the daily_stats rollups for the freshly generated data
+	fmt.Println("Rebuilding daily stats rollups...")
+	store := services.NewMongoTransactionStore(config.DB)
+	if err := store.RebuildDailyStats(context.Background(), time.Unix(0, 0), time.Now().AddDate(0, 0, 1)); err != nil {
+		log.Fatal("Failed to rebuild daily stats:", err)
+	}
+
 	fmt.Println("Data generation completed successfully!")
 }
 
@@ -57,7 +78,120 @@ func generateUserIDs(count int) []primitive.ObjectID {
 	return userIDs
 }
 
-func generateTransactions(userIDs []primitive.ObjectID, rounds int) error {
+// rateWalk describes the simulated USD price of a currency: its starting
+// price, the standard deviation of its hourly relative change and whether
+// it is a stablecoin pegged around a dollar
+type rateWalk struct {
+	start      float64
+	volatility float64
+	pegged     bool
+}
+
+// rateWalks are the walks of the default currencies. Other currencies
+// start from their latest stored USD rate.
+var rateWalks = map[string]rateWalk{
+	"BTC":  {45000.0, 0.006, false},
+	"ETH":  {3000.0, 0.008, false},
+	"USDT": {1.0, 0.0005, true},
+}
+
+// defaultVolatility is the hourly volatility of currencies without a walk
+const defaultVolatility = 0.01
+
+// generateExchangeRates stores an hourly random walk of USD rates per
+// currency in exchange_rates and returns them for converting amounts,
+// together with the currencies that got rates. Uploaded rates are left in
+// place. A currency with neither a walk nor a stored rate to start from is
+// skipped.
+func generateExchangeRates(codes []string, from, to time.Time) (*services.MemoryRateProvider, []string, error) {
+	ctx := context.Background()
+	provider := services.NewMongoRateProvider(config.DB)
+
+	walks := make(map[string]rateWalk)
+	var generated []string
+	for _, currency := range codes {
+		walk, ok := rateWalks[currency]
+		if !ok {
+			latest, err := provider.RateAt(ctx, currency, services.QuoteUSD, time.Now())
+			if err != nil {
+				fmt.Printf("Skipping %s: no exchange rate to start its walk from\n", currency)
+				continue
+			}
+			walk = rateWalk{start: latest.Rate, volatility: defaultVolatility}
+		}
+		walks[currency] = walk
+		generated = append(generated, currency)
+	}
+
+	// Only rates from an earlier run are cleared; uploaded rates are kept
+	// and the walk skips the instants they cover
+	fmt.Println("Clearing previously generated exchange rates...")
+	collection := config.DB.Collection("exchange_rates")
+	if _, err := collection.DeleteMany(ctx, bson.M{"source": "generated"}); err != nil {
+		return nil, nil, fmt.Errorf("failed to clear generated exchange rates: %v", err)
+	}
+
+	start := from.UTC().Truncate(time.Hour)
+	type rateKey struct {
+		base string
+		at   time.Time
+	}
+	uploaded := make(map[rateKey]bool)
+	cursor, err := collection.Find(ctx, bson.M{
+		"base":        bson.M{"$in": generated},
+		"quote":       services.QuoteUSD,
+		"effectiveAt": bson.M{"$gte": start, "$lte": to},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load uploaded exchange rates: %v", err)
+	}
+	var existing []models.ExchangeRate
+	if err := cursor.All(ctx, &existing); err != nil {
+		return nil, nil, fmt.Errorf("failed to load uploaded exchange rates: %v", err)
+	}
+	for _, rate := range existing {
+		uploaded[rateKey{base: rate.Base, at: rate.EffectiveAt.UTC()}] = true
+	}
+
+	var rates []models.ExchangeRate
+	for currency, walk := range walks {
+		price := walk.start
+		for at := start; !at.After(to); at = at.Add(time.Hour) {
+			if !uploaded[rateKey{base: currency, at: at}] {
+				rates = append(rates, models.ExchangeRate{
+					Base:        currency,
+					Quote:       services.QuoteUSD,
+					Rate:        price,
+					EffectiveAt: at,
+					Source:      "generated",
+				})
+			}
+			price *= 1 + rand.NormFloat64()*walk.volatility
+			// Keep stablecoins pegged around a dollar
+			if walk.pegged {
+				price += (1 - price) * 0.1
+			}
+		}
+	}
+
+	for i := 0; i < len(rates); i += BATCH_SIZE {
+		end := i + BATCH_SIZE
+		if end > len(rates) {
+			end = len(rates)
+		}
+		if _, _, err := provider.SaveRates(ctx, rates[i:end]); err != nil {
+			return nil, nil, fmt.Errorf("failed to insert exchange rates: %v", err)
+		}
+	}
+	fmt.Printf("Generated %d exchange rates\n", len(rates))
+
+	// Amounts are converted with the uploaded rates too, as the API would
+	return services.NewMemoryRateProvider(append(rates, existing...)...), generated, nil
+}
+
+// generateTransactions generates rounds in the given currencies, with
+// amounts rounded to the decimals of their currency
+func generateTransactions(userIDs []primitive.ObjectID, rounds int, rates services.RateProvider, codes []string, currencies *services.CurrencyRegistry) error {
 	collection := config.DB.Collection("transactions")
 	ctx := context.Background()
 
@@ -82,20 +216,26 @@ func generateTransactions(userIDs []primitive.ObjectID, rounds int) error {
 		// Random user for this round
 		userID := userIDs[rand.Intn(len(userIDs))]
 		roundID := fmt.Sprintf("round_%d_%d", time.Now().UnixNano(), round)
-		currency := utils.GetRandomCurrency()
+		currency := utils.GetRandomCurrency(codes)
 
 		// Random time within the past year
 		now := time.Now()
 		pastYear := now.AddDate(-1, 0, 0)
 		randomTime := pastYear.Add(time.Duration(rand.Int63n(int64(now.Sub(pastYear)))))
 
-		// Generate wager amount
-		wagerAmount := utils.GetRandomAmount(currency)
-		wagerUSDAmount := utils.ConvertToUSD(wagerAmount, currency)
+		// Generate wager amount worth 10 to 1000 USD at the time
+		price, err := rates.RateAt(ctx, currency, services.QuoteUSD, randomTime)
+		if err != nil {
+			return err
+		}
+		wagerAmount := utils.GetRandomAmount(price.Rate)
 
 		// Create wager transaction
-		wagerAmountDecimal, _ := primitive.ParseDecimal128(fmt.Sprintf("%.8f", wagerAmount))
-		wagerUSDDecimal, _ := primitive.ParseDecimal128(fmt.Sprintf("%.2f", wagerUSDAmount))
+		wagerAmountDecimal, _ := primitive.ParseDecimal128(fmt.Sprintf("%.*f", currencies.Decimals(currency), wagerAmount))
+		wagerUSDDecimal, err := services.ConvertToUSD(ctx, rates, wagerAmountDecimal, currency, randomTime, currencies.Decimals(services.QuoteUSD))
+		if err != nil {
+			return err
+		}
 		
 		wagerTransaction := models.Transaction{
 			ID:        primitive.NewObjectID(),
@@ -114,11 +254,13 @@ func generateTransactions(userIDs []primitive.ObjectID, rounds int) error {
 		payoutTime := randomTime.Add(time.Duration(rand.Intn(300)) * time.Second) // 0-5 minutes later
 		payoutMultiplier := utils.GetRandomPayoutMultiplier()
 		payoutAmount := wagerAmount * payoutMultiplier
-		payoutUSDAmount := utils.ConvertToUSD(payoutAmount, currency)
 
 		// Create payout transaction
-		payoutAmountDecimal, _ := primitive.ParseDecimal128(fmt.Sprintf("%.8f", payoutAmount))
-		payoutUSDDecimal, _ := primitive.ParseDecimal128(fmt.Sprintf("%.2f", payoutUSDAmount))
+		payoutAmountDecimal, _ := primitive.ParseDecimal128(fmt.Sprintf("%.*f", currencies.Decimals(currency), payoutAmount))
+		payoutUSDDecimal, err := services.ConvertToUSD(ctx, rates, payoutAmountDecimal, currency, payoutTime, currencies.Decimals(services.QuoteUSD))
+		if err != nil {
+			return err
+		}
 		
 		payoutTransaction := models.Transaction{
 			ID:        primitive.NewObjectID(),
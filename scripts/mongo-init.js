@@ -21,9 +21,44 @@ db.createCollection('transactions');
 
 // Create indexes for better performance
 db.transactions.createIndex({ "createdAt": 1 });
+db.transactions.createIndex({ "createdAt": 1, "_id": 1 });
 db.transactions.createIndex({ "userId": 1 });
 db.transactions.createIndex({ "userId": 1, "createdAt": 1 });
 db.transactions.createIndex({ "roundId": 1 });
 db.transactions.createIndex({ "type": 1 });
+db.transactions.createIndex(
+  { "idempotencyKey": 1 },
+  { unique: true, partialFilterExpression: { "idempotencyKey": { $type: "string" } } }
+);
+db.transactions.createIndex({ "roundId": 1, "type": 1, "roundSlot": 1 }, { unique: true });
+
+// Create the daily rollup collection used by GGR and wager volume
+db.createCollection('daily_stats');
+db.daily_stats.createIndex({ "day": 1, "currency": 1, "type": 1 }, { unique: true });
+db.createCollection('daily_stat_users');
+db.daily_stat_users.createIndex({ "day": 1, "currency": 1, "type": 1, "userId": 1 }, { unique: true });
+db.createCollection('daily_stat_transactions');
+db.daily_stat_transactions.createIndex({ "day": 1, "rebuildId": 1 });
+
+// Create the time-stamped exchange rates used to convert amounts to USD
+db.createCollection('exchange_rates');
+db.exchange_rates.createIndex({ "base": 1, "quote": 1, "effectiveAt": 1 }, { unique: true });
+
+// Create the currency registry with the default currencies
+db.createCollection('currencies');
+db.currencies.createIndex({ "code": 1 }, { unique: true });
+db.currencies.insertMany([
+  { code: 'BTC', name: 'Bitcoin', decimals: 8, type: 'crypto', enabled: true },
+  { code: 'ETH', name: 'Ether', decimals: 8, type: 'crypto', enabled: true },
+  { code: 'USDT', name: 'Tether', decimals: 8, type: 'crypto', enabled: true },
+  { code: 'USD', name: 'US Dollar', decimals: 2, type: 'fiat', enabled: true },
+  { code: 'EUR', name: 'Euro', decimals: 2, type: 'fiat', enabled: true },
+  { code: 'GBP', name: 'Pound Sterling', decimals: 2, type: 'fiat', enabled: true }
+]);
+
+// API keys are created through POST /admin/api_keys
+db.createCollection('api_keys');
+db.api_keys.createIndex({ "hash": 1 }, { unique: true });
+db.api_keys.createIndex({ "owner": 1, "createdAt": -1 });
 
 print('Database initialization completed successfully!');
\ No newline at end of file
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"admin_statistics_api/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKeyPrefix starts every API key secret, telling keys apart from JWTs
+const APIKeyPrefix = "ask_"
+
+const (
+	// apiKeyPrefixLength is how much of the secret is kept in clear
+	apiKeyPrefixLength = len(APIKeyPrefix) + 8
+	// lastUsedInterval throttles lastUsedAt writes per key
+	lastUsedInterval = time.Minute
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrInvalidAPIKey  = errors.New("API key is not valid")
+	ErrAPIKeyRevoked  = errors.New("API key has been revoked")
+	ErrAPIKeyExpired  = errors.New("API key has expired")
+)
+
+// APIKeyStore persists API keys by the hash of their secret
+type APIKeyStore interface {
+	// InsertKey stores a new key and returns it with its ID
+	InsertKey(ctx context.Context, key models.APIKey) (models.APIKey, error)
+	// ListKeys lists keys, newest first, of one owner when owner is set.
+	// Revoked keys are only listed with includeRevoked.
+	ListKeys(ctx context.Context, owner string, includeRevoked bool) ([]models.APIKey, error)
+	// KeyByHash returns the key whose secret has the given hash
+	KeyByHash(ctx context.Context, hash string) (models.APIKey, error)
+	// ReplaceSecret gives an active key a new secret
+	ReplaceSecret(ctx context.Context, id primitive.ObjectID, prefix, hash string, at time.Time) (models.APIKey, error)
+	// RevokeKey revokes an active key
+	RevokeKey(ctx context.Context, id primitive.ObjectID, at time.Time) (models.APIKey, error)
+	// TouchKey records that a key was used at the given instant
+	TouchKey(ctx context.Context, id primitive.ObjectID, at time.Time) error
+}
+
+type cachedAPIKey struct {
+	key      models.APIKey
+	loadedAt time.Time
+}
+
+// APIKeyService issues API keys and verifies them for AuthMiddleware.
+// Verified keys are cached in process for cacheTTL, so a key rotated or
+// revoked on another instance keeps working there for at most that long.
+type APIKeyService struct {
+	store    APIKeyStore
+	cacheTTL time.Duration
+
+	mu      sync.Mutex
+	cache   map[string]cachedAPIKey
+	touched map[primitive.ObjectID]time.Time
+}
+
+func NewAPIKeyService(store APIKeyStore, cacheTTL time.Duration) *APIKeyService {
+	return &APIKeyService{
+		store:    store,
+		cacheTTL: cacheTTL,
+		cache:    make(map[string]cachedAPIKey),
+		touched:  make(map[primitive.ObjectID]time.Time),
+	}
+}
+
+// CreateKey issues a new key. The secret is returned once and only its
+// hash is stored.
+func (s *APIKeyService) CreateKey(ctx context.Context, req models.APIKeyRequest) (models.APIKeySecret, error) {
+	secret, prefix, hash, err := newAPIKeySecret()
+	if err != nil {
+		return models.APIKeySecret{}, err
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresAt != nil {
+		at := req.ExpiresAt.UTC()
+		expiresAt = &at
+	}
+
+	key, err := s.store.InsertKey(ctx, models.APIKey{
+		Name:      req.Name,
+		Owner:     req.Owner,
+		Prefix:    prefix,
+		Hash:      hash,
+		Scopes:    normalizeScopes(req.Scopes),
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return models.APIKeySecret{}, err
+	}
+	return models.APIKeySecret{Key: key, Secret: secret}, nil
+}
+
+func (s *APIKeyService) ListKeys(ctx context.Context, owner string, includeRevoked bool) ([]models.APIKey, error) {
+	return s.store.ListKeys(ctx, owner, includeRevoked)
+}
+
+// RotateKey replaces the secret of an active key, keeping its name,
+// scopes and expiry. The old secret stops working immediately on this
+// instance.
+func (s *APIKeyService) RotateKey(ctx context.Context, id primitive.ObjectID) (models.APIKeySecret, error) {
+	secret, prefix, hash, err := newAPIKeySecret()
+	if err != nil {
+		return models.APIKeySecret{}, err
+	}
+
+	key, err := s.store.ReplaceSecret(ctx, id, prefix, hash, time.Now().UTC())
+	if err != nil {
+		return models.APIKeySecret{}, err
+	}
+	s.forget(id)
+	return models.APIKeySecret{Key: key, Secret: secret}, nil
+}
+
+// RevokeKey revokes an active key for good
+func (s *APIKeyService) RevokeKey(ctx context.Context, id primitive.ObjectID) (models.APIKey, error) {
+	key, err := s.store.RevokeKey(ctx, id, time.Now().UTC())
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	s.forget(id)
+	return key, nil
+}
+
+// VerifyKey returns the active key with the given secret and records its
+// use
+func (s *APIKeyService) VerifyKey(ctx context.Context, secret string) (models.APIKey, error) {
+	if !strings.HasPrefix(secret, APIKeyPrefix) {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+	hash := hashAPIKey(secret)
+	now := time.Now()
+
+	s.mu.Lock()
+	cached, ok := s.cache[hash]
+	s.mu.Unlock()
+
+	key := cached.key
+	if !ok || now.Sub(cached.loadedAt) >= s.cacheTTL {
+		var err error
+		key, err = s.store.KeyByHash(ctx, hash)
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			return models.APIKey{}, ErrInvalidAPIKey
+		}
+		if err != nil {
+			return models.APIKey{}, err
+		}
+
+		s.mu.Lock()
+		s.cache[hash] = cachedAPIKey{key: key, loadedAt: now}
+		s.mu.Unlock()
+	}
+
+	if key.RevokedAt != nil {
+		return models.APIKey{}, ErrAPIKeyRevoked
+	}
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return models.APIKey{}, ErrAPIKeyExpired
+	}
+
+	s.touch(key.ID, now)
+	return key, nil
+}
+
+// touch records the use of a key in the background, at most once per
+// lastUsedInterval
+func (s *APIKeyService) touch(id primitive.ObjectID, at time.Time) {
+	s.mu.Lock()
+	if last, ok := s.touched[id]; ok && at.Sub(last) < lastUsedInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.touched[id] = at
+	s.mu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.store.TouchKey(ctx, id, at.UTC()); err != nil {
+			log.Printf("Failed to record use of API key %s: %v", id.Hex(), err)
+		}
+	}()
+}
+
+// forget drops a key from the cache after its secret or state changed
+func (s *APIKeyService) forget(id primitive.ObjectID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, cached := range s.cache {
+		if cached.key.ID == id {
+			delete(s.cache, hash)
+		}
+	}
+}
+
+// newAPIKeySecret generates a secret with 256 random bits, its clear
+// prefix and its hash
+func newAPIKeySecret() (secret, prefix, hash string, err error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", "", err
+	}
+	secret = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(random)
+	return secret, secret[:apiKeyPrefixLength], hashAPIKey(secret), nil
+}
+
+// hashAPIKey hashes a secret for storage and lookup. Secrets are random,
+// so a fast unsalted hash is enough and keeps lookups indexable.
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeScopes sorts scopes and drops duplicates
+func normalizeScopes(scopes []string) []string {
+	seen := make(map[string]bool)
+	normalized := []string{}
+	for _, scope := range scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"admin_statistics_api/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeAPIKeyStore keeps keys in a map and counts lookups
+type fakeAPIKeyStore struct {
+	mu      sync.Mutex
+	keys    map[primitive.ObjectID]models.APIKey
+	lookups int
+}
+
+func newFakeAPIKeyStore() *fakeAPIKeyStore {
+	return &fakeAPIKeyStore{keys: make(map[primitive.ObjectID]models.APIKey)}
+}
+
+func (s *fakeAPIKeyStore) InsertKey(ctx context.Context, key models.APIKey) (models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key.ID = primitive.NewObjectID()
+	s.keys[key.ID] = key
+	return key, nil
+}
+
+func (s *fakeAPIKeyStore) ListKeys(ctx context.Context, owner string, includeRevoked bool) ([]models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []models.APIKey
+	for _, key := range s.keys {
+		if (owner == "" || key.Owner == owner) && (includeRevoked || key.RevokedAt == nil) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *fakeAPIKeyStore) KeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lookups++
+	for _, key := range s.keys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+	return models.APIKey{}, ErrAPIKeyNotFound
+}
+
+func (s *fakeAPIKeyStore) ReplaceSecret(ctx context.Context, id primitive.ObjectID, prefix, hash string, at time.Time) (models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return models.APIKey{}, ErrAPIKeyNotFound
+	}
+	key.Prefix, key.Hash, key.RotatedAt = prefix, hash, &at
+	s.keys[id] = key
+	return key, nil
+}
+
+func (s *fakeAPIKeyStore) RevokeKey(ctx context.Context, id primitive.ObjectID, at time.Time) (models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return models.APIKey{}, ErrAPIKeyNotFound
+	}
+	key.RevokedAt = &at
+	s.keys[id] = key
+	return key, nil
+}
+
+func (s *fakeAPIKeyStore) TouchKey(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	return nil
+}
+
+// expire backdates the expiry of a stored key, as if time had passed
+func (s *fakeAPIKeyStore) expire(id primitive.ObjectID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := s.keys[id]
+	past := time.Now().Add(-time.Second)
+	key.ExpiresAt = &past
+	s.keys[id] = key
+}
+
+func TestAPIKeyServiceVerifyKey(t *testing.T) {
+	ctx := context.Background()
+	request := models.APIKeyRequest{Name: "dashboard", Owner: "data-team", Scopes: []string{models.ScopeStatsRead}}
+
+	tests := []struct {
+		name string
+		// prepare returns the secret to verify
+		prepare func(service *APIKeyService, store *fakeAPIKeyStore) string
+		wantErr error
+	}{
+		{
+			name: "active key",
+			prepare: func(service *APIKeyService, store *fakeAPIKeyStore) string {
+				created, _ := service.CreateKey(ctx, request)
+				return created.Secret
+			},
+		},
+		{
+			name: "unknown secret",
+			prepare: func(service *APIKeyService, store *fakeAPIKeyStore) string {
+				return APIKeyPrefix + "not-a-real-secret"
+			},
+			wantErr: ErrInvalidAPIKey,
+		},
+		{
+			name: "missing prefix",
+			prepare: func(service *APIKeyService, store *fakeAPIKeyStore) string {
+				return "eyJhbGciOiJIUzI1NiJ9"
+			},
+			wantErr: ErrInvalidAPIKey,
+		},
+		{
+			name: "revoked after it was cached",
+			prepare: func(service *APIKeyService, store *fakeAPIKeyStore) string {
+				created, _ := service.CreateKey(ctx, request)
+				service.VerifyKey(ctx, created.Secret)
+				service.RevokeKey(ctx, created.Key.ID)
+				return created.Secret
+			},
+			wantErr: ErrAPIKeyRevoked,
+		},
+		{
+			name: "expired",
+			prepare: func(service *APIKeyService, store *fakeAPIKeyStore) string {
+				created, _ := service.CreateKey(ctx, request)
+				store.expire(created.Key.ID)
+				return created.Secret
+			},
+			wantErr: ErrAPIKeyExpired,
+		},
+		{
+			name: "old secret after rotation",
+			prepare: func(service *APIKeyService, store *fakeAPIKeyStore) string {
+				created, _ := service.CreateKey(ctx, request)
+				service.VerifyKey(ctx, created.Secret)
+				service.RotateKey(ctx, created.Key.ID)
+				return created.Secret
+			},
+			wantErr: ErrInvalidAPIKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeAPIKeyStore()
+			service := NewAPIKeyService(store, time.Minute)
+			secret := tt.prepare(service, store)
+
+			key, err := service.VerifyKey(ctx, secret)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyKey: %v", err)
+			}
+			if key.Owner != request.Owner || len(key.Scopes) != 1 || key.Scopes[0] != models.ScopeStatsRead {
+				t.Errorf("got key %+v, want the created key", key)
+			}
+		})
+	}
+}
+
+func TestAPIKeyServiceCachesVerifiedKeys(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeAPIKeyStore()
+	service := NewAPIKeyService(store, time.Minute)
+
+	created, err := service.CreateKey(ctx, models.APIKeyRequest{Name: "etl", Owner: "data-team", Scopes: []string{models.ScopeTransactionsRead}})
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+	if created.Key.Hash == created.Secret || created.Key.Prefix != created.Secret[:apiKeyPrefixLength] {
+		t.Fatalf("got hash %q and prefix %q, want the hash and prefix of the secret", created.Key.Hash, created.Key.Prefix)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := service.VerifyKey(ctx, created.Secret); err != nil {
+			t.Fatalf("VerifyKey: %v", err)
+		}
+	}
+	if store.lookups != 1 {
+		t.Errorf("got %d store lookups, want 1 within the cache TTL", store.lookups)
+	}
+
+	// Expiry is checked on every call, including cached ones
+	store.expire(created.Key.ID)
+	service.forget(created.Key.ID)
+	if _, err := service.VerifyKey(ctx, created.Secret); !errors.Is(err, ErrAPIKeyExpired) {
+		t.Errorf("got error %v, want %v", err, ErrAPIKeyExpired)
+	}
+}
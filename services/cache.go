@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cache stores serialized statistics results. A nil Cache disables caching.
+type Cache interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key string, value string, expiration time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	// Scan returns the keys matching a glob-style pattern
+	Scan(ctx context.Context, pattern string) ([]string, error)
+}
+
+// Prefixes of the cached statistics keys
+const (
+	ggrCachePrefix            = "ggr"
+	wagerVolumeCachePrefix    = "wager_volume"
+	userPercentileCachePrefix = "user_percentile"
+	userSummaryCachePrefix    = "user_summary"
+	leaderboardCachePrefix    = "leaderboard"
+)
+
+// statsCachePrefixes lists every key family derived from transactions
+var statsCachePrefixes = []string{
+	ggrCachePrefix,
+	wagerVolumeCachePrefix,
+	userPercentileCachePrefix,
+	userSummaryCachePrefix,
+	leaderboardCachePrefix,
+}
+
+// statsCacheKey builds keys of the form prefix:parts...:from:to with the
+// range in Unix milliseconds, the precision MongoDB stores. The time range
+// is always last so that cacheKeyRange can recover it when new
+// transactions make an entry stale.
+func statsCacheKey(prefix string, from, to time.Time, parts ...string) string {
+	fields := append([]string{prefix}, parts...)
+	fields = append(fields, strconv.FormatInt(from.UnixMilli(), 10), strconv.FormatInt(to.UnixMilli(), 10))
+	return strings.Join(fields, ":")
+}
+
+// cacheKeyRange extracts the time range from a key built by statsCacheKey
+func cacheKeyRange(key string) (time.Time, time.Time, bool) {
+	fields := strings.Split(key, ":")
+	if len(fields) < 3 {
+		return time.Time{}, time.Time{}, false
+	}
+	from, err := strconv.ParseInt(fields[len(fields)-2], 10, 64)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	to, err := strconv.ParseInt(fields[len(fields)-1], 10, 64)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	return time.UnixMilli(from), time.UnixMilli(to), true
+}
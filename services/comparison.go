@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"admin_statistics_api/models"
+)
+
+// currencyTotal is a currency's total in its own units and in USD
+type currencyTotal struct {
+	amount   models.Decimal
+	usdValue models.Decimal
+}
+
+// runConcurrently runs both calls at once and returns the error that made
+// them fail rather than the cancellation it caused in the other call
+func runConcurrently(ctx context.Context, first, second func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var secondErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if secondErr = second(ctx); secondErr != nil {
+			cancel()
+		}
+	}()
+
+	firstErr := first(ctx)
+	if firstErr != nil {
+		cancel()
+	}
+	wg.Wait()
+
+	if firstErr != nil && !(secondErr != nil && errors.Is(firstErr, context.Canceled)) {
+		return firstErr
+	}
+	return secondErr
+}
+
+// CompareGrossGamingRevenue calculates GGR for [from, to] and for the
+// comparison window [prevFrom, prevTo] concurrently, returning the current
+// GGR and the deltas between the two
+func (s *StatisticsService) CompareGrossGamingRevenue(ctx context.Context, from, to, prevFrom, prevTo time.Time) ([]models.GrossGamingRevenue, *models.PeriodComparison, error) {
+	var current, previous []models.GrossGamingRevenue
+	err := runConcurrently(ctx,
+		func(ctx context.Context) (err error) {
+			current, err = s.GetGrossGamingRevenue(ctx, from, to)
+			return err
+		},
+		func(ctx context.Context) (err error) {
+			previous, err = s.GetGrossGamingRevenue(ctx, prevFrom, prevTo)
+			return err
+		},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return current, comparePeriods(ggrTotals(current), ggrTotals(previous)), nil
+}
+
+// CompareWagerVolume calculates wager volume for [from, to] and for the
+// comparison window [prevFrom, prevTo] concurrently, returning the current
+// buckets and the deltas between the windows' totals
+func (s *StatisticsService) CompareWagerVolume(ctx context.Context, from, to, prevFrom, prevTo time.Time, granularity string, loc *time.Location) ([]models.WagerVolume, *models.PeriodComparison, error) {
+	var current, previous []models.WagerVolume
+	err := runConcurrently(ctx,
+		func(ctx context.Context) (err error) {
+			current, err = s.GetWagerVolume(ctx, from, to, granularity, loc)
+			return err
+		},
+		func(ctx context.Context) (err error) {
+			previous, err = s.GetWagerVolume(ctx, prevFrom, prevTo, granularity, loc)
+			return err
+		},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return current, comparePeriods(volumeTotals(current), volumeTotals(previous)), nil
+}
+
+// CompareDailyWagerVolume is CompareWagerVolume with day buckets in the
+// daily volume shape
+func (s *StatisticsService) CompareDailyWagerVolume(ctx context.Context, from, to, prevFrom, prevTo time.Time, loc *time.Location) ([]models.DailyWagerVolume, *models.PeriodComparison, error) {
+	volumes, comparison, err := s.CompareWagerVolume(ctx, from, to, prevFrom, prevTo, GranularityDay, loc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dailyWagerVolume(volumes, loc), comparison, nil
+}
+
+func ggrTotals(results []models.GrossGamingRevenue) map[string]currencyTotal {
+	totals := make(map[string]currencyTotal)
+	for _, ggr := range results {
+		total := totals[ggr.Currency]
+		total.amount = total.amount.Add(ggr.Amount)
+		total.usdValue = total.usdValue.Add(ggr.USDValue)
+		totals[ggr.Currency] = total
+	}
+	return totals
+}
+
+func volumeTotals(results []models.WagerVolume) map[string]currencyTotal {
+	totals := make(map[string]currencyTotal)
+	for _, volume := range results {
+		total := totals[volume.Currency]
+		total.amount = total.amount.Add(volume.Amount)
+		total.usdValue = total.usdValue.Add(volume.USDValue)
+		totals[volume.Currency] = total
+	}
+	return totals
+}
+
+// comparePeriods builds the deltas for every currency present in either
+// window, sorted by currency
+func comparePeriods(current, previous map[string]currencyTotal) *models.PeriodComparison {
+	currencies := make(map[string]bool)
+	for currency := range current {
+		currencies[currency] = true
+	}
+	for currency := range previous {
+		currencies[currency] = true
+	}
+
+	comparison := &models.PeriodComparison{ByCurrency: []models.CurrencyDelta{}}
+	var currentUSD, previousUSD models.Decimal
+	for currency := range currencies {
+		cur, prev := current[currency], previous[currency]
+		comparison.ByCurrency = append(comparison.ByCurrency, models.CurrencyDelta{
+			Currency: currency,
+			Amount:   valueDelta(cur.amount, prev.amount),
+			USDValue: valueDelta(cur.usdValue, prev.usdValue),
+		})
+		currentUSD = currentUSD.Add(cur.usdValue)
+		previousUSD = previousUSD.Add(prev.usdValue)
+	}
+	sort.Slice(comparison.ByCurrency, func(i, j int) bool {
+		return comparison.ByCurrency[i].Currency < comparison.ByCurrency[j].Currency
+	})
+	comparison.TotalUSD = valueDelta(currentUSD, previousUSD)
+
+	return comparison
+}
+
+func valueDelta(current, previous models.Decimal) models.ValueDelta {
+	delta := models.ValueDelta{
+		Current:  current,
+		Previous: previous,
+		Change:   current.Sub(previous),
+	}
+	if !previous.IsZero() {
+		percent := delta.Change.Ratio(previous.Abs()) * 100
+		delta.ChangePercent = &percent
+	}
+	return delta
+}
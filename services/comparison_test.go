@@ -0,0 +1,31 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRunConcurrentlyReturnsTheCause(t *testing.T) {
+	failed := errors.New("query failed")
+	waitForCancel := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	fail := func(ctx context.Context) error {
+		return failed
+	}
+
+	if err := runConcurrently(context.Background(), waitForCancel, fail); err != failed {
+		t.Errorf("second call failing: got %v, want %v", err, failed)
+	}
+	if err := runConcurrently(context.Background(), fail, waitForCancel); err != failed {
+		t.Errorf("first call failing: got %v, want %v", err, failed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := runConcurrently(ctx, waitForCancel, waitForCancel); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled by the caller: got %v, want context.Canceled", err)
+	}
+}
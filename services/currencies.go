@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"admin_statistics_api/models"
+)
+
+// defaultCurrencyDecimals is the precision of currencies missing from the
+// registry
+const defaultCurrencyDecimals = 2
+
+// DefaultCurrencies seed an empty currencies collection
+var DefaultCurrencies = []models.Currency{
+	{Code: "BTC", Name: "Bitcoin", Decimals: 8, Type: models.CurrencyTypeCrypto, Enabled: true},
+	{Code: "ETH", Name: "Ether", Decimals: 8, Type: models.CurrencyTypeCrypto, Enabled: true},
+	{Code: "USDT", Name: "Tether", Decimals: 8, Type: models.CurrencyTypeCrypto, Enabled: true},
+	{Code: "USD", Name: "US Dollar", Decimals: 2, Type: models.CurrencyTypeFiat, Enabled: true},
+	{Code: "EUR", Name: "Euro", Decimals: 2, Type: models.CurrencyTypeFiat, Enabled: true},
+	{Code: "GBP", Name: "Pound Sterling", Decimals: 2, Type: models.CurrencyTypeFiat, Enabled: true},
+}
+
+var ErrUnknownCurrency = errors.New("currency is not supported")
+
+// CurrencySource lists the currencies known to the registry
+type CurrencySource interface {
+	Currencies(ctx context.Context) ([]models.Currency, error)
+}
+
+// StaticCurrencies is a fixed CurrencySource, for tools and stores that
+// run without MongoDB
+type StaticCurrencies []models.Currency
+
+func (c StaticCurrencies) Currencies(ctx context.Context) ([]models.Currency, error) {
+	return c, nil
+}
+
+// NormalizeCurrency upper-cases the code of a currency and checks its
+// type and precision
+func NormalizeCurrency(currency models.Currency) (models.Currency, error) {
+	currency.Code = strings.ToUpper(strings.TrimSpace(currency.Code))
+	if currency.Code == "" {
+		return currency, errors.New("code is required")
+	}
+	if currency.Type != models.CurrencyTypeCrypto && currency.Type != models.CurrencyTypeFiat {
+		return currency, fmt.Errorf("type of %s must be crypto or fiat, got %q", currency.Code, currency.Type)
+	}
+	if currency.Decimals < 0 || currency.Decimals > 18 {
+		return currency, fmt.Errorf("decimals of %s must be between 0 and 18, got %d", currency.Code, currency.Decimals)
+	}
+	return currency, nil
+}
+
+// CurrencyRegistry is the in-memory view of the supported currencies. It
+// is loaded from its source at startup and reloaded periodically by Run,
+// so currencies can be added or disabled without a redeploy.
+type CurrencyRegistry struct {
+	source CurrencySource
+
+	mu          sync.RWMutex
+	byCode      map[string]models.Currency
+	refreshedAt time.Time
+}
+
+// NewCurrencyRegistry creates an empty registry; call Load before use
+func NewCurrencyRegistry(source CurrencySource) *CurrencyRegistry {
+	return &CurrencyRegistry{
+		source: source,
+		byCode: make(map[string]models.Currency),
+	}
+}
+
+// Load replaces the registry with the currencies of its source. Invalid
+// entries are logged and skipped; a failed load keeps the previous set, as
+// does a source without a single valid currency, which would otherwise
+// make every transaction fail validation.
+func (r *CurrencyRegistry) Load(ctx context.Context) error {
+	currencies, err := r.source.Currencies(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load currencies: %v", err)
+	}
+
+	byCode := make(map[string]models.Currency, len(currencies))
+	for _, currency := range currencies {
+		normalized, err := NormalizeCurrency(currency)
+		if err != nil {
+			log.Printf("Skipping currency: %v", err)
+			continue
+		}
+		byCode[normalized.Code] = normalized
+	}
+	if len(byCode) == 0 {
+		return fmt.Errorf("no valid currencies found, keeping the previous registry")
+	}
+
+	r.mu.Lock()
+	r.byCode = byCode
+	r.refreshedAt = time.Now().UTC()
+	r.mu.Unlock()
+	return nil
+}
+
+// Run reloads the registry every interval until ctx is cancelled
+func (r *CurrencyRegistry) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Load(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Currency registry: %v", err)
+			}
+		}
+	}
+}
+
+// Lookup returns the registered currency with the given code
+func (r *CurrencyRegistry) Lookup(code string) (models.Currency, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	currency, ok := r.byCode[code]
+	return currency, ok
+}
+
+// Known reports whether a currency is registered, enabled or not
+func (r *CurrencyRegistry) Known(code string) bool {
+	_, ok := r.Lookup(code)
+	return ok
+}
+
+// Enabled reports whether new transactions may use a currency
+func (r *CurrencyRegistry) Enabled(code string) bool {
+	currency, ok := r.Lookup(code)
+	return ok && currency.Enabled
+}
+
+// Decimals is the precision amounts in a currency are presented with
+func (r *CurrencyRegistry) Decimals(code string) int32 {
+	if currency, ok := r.Lookup(code); ok {
+		return currency.Decimals
+	}
+	return defaultCurrencyDecimals
+}
+
+// List returns the registered currencies sorted by code, optionally only
+// those of one type
+func (r *CurrencyRegistry) List(currencyType string) []models.Currency {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	currencies := []models.Currency{}
+	for _, currency := range r.byCode {
+		if currencyType != "" && currency.Type != currencyType {
+			continue
+		}
+		currencies = append(currencies, currency)
+	}
+	sort.Slice(currencies, func(i, j int) bool {
+		return currencies[i].Code < currencies[j].Code
+	})
+	return currencies
+}
+
+// EnabledCodes returns the codes of the enabled currencies of a type,
+// sorted
+func (r *CurrencyRegistry) EnabledCodes(currencyType string) []string {
+	var codes []string
+	for _, currency := range r.List(currencyType) {
+		if currency.Enabled {
+			codes = append(codes, currency.Code)
+		}
+	}
+	return codes
+}
+
+// RefreshedAt is when the registry was last loaded
+func (r *CurrencyRegistry) RefreshedAt() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.refreshedAt
+}
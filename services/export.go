@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"admin_statistics_api/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrInvalidCursor = errors.New("invalid export cursor")
+
+// Token encodes the position as an opaque cursor for resuming an export.
+// MongoDB stores millisecond timestamps, so milliseconds are enough.
+func (p TransactionPosition) Token() string {
+	raw := strconv.FormatInt(p.CreatedAt.UnixMilli(), 10) + ":" + p.ID.Hex()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseTransactionPosition decodes a cursor produced by Token
+func ParseTransactionPosition(token string) (*TransactionPosition, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+	millis, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := primitive.ObjectIDFromHex(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &TransactionPosition{CreatedAt: time.UnixMilli(millis).UTC(), ID: id}, nil
+}
+
+// PositionOf returns a transaction's place in the export order
+func PositionOf(tx models.Transaction) TransactionPosition {
+	return TransactionPosition{CreatedAt: tx.CreatedAt, ID: tx.ID}
+}
+
+// Export streams the transactions matching the query to fn in
+// (createdAt, _id) order. Cancelling ctx, e.g. when the client goes away,
+// stops the export and releases the cursor.
+func (s *TransactionService) Export(ctx context.Context, query TransactionQuery, fn func(models.Transaction) error) error {
+	return s.store.StreamTransactions(ctx, query, fn)
+}
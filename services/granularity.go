@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+	"time"
+)
+
+// Wager volume bucket sizes. Weeks are ISO weeks starting on Monday.
+const (
+	GranularityMinute  = "minute"
+	GranularityHour    = "hour"
+	GranularityDay     = "day"
+	GranularityWeek    = "week"
+	GranularityMonth   = "month"
+	GranularityQuarter = "quarter"
+	GranularityYear    = "year"
+)
+
+// maxVolumeBuckets caps the number of time buckets a single wager volume
+// query may produce, so minute buckets cannot be requested over a year
+const maxVolumeBuckets = 10000
+
+var (
+	ErrUnknownGranularity = errors.New("unknown granularity")
+	ErrTooManyBuckets     = errors.New("time range is too long for the requested granularity")
+)
+
+// nominalBucketSize is the approximate length of a bucket, used only to
+// estimate how many buckets a range spans
+var nominalBucketSize = map[string]time.Duration{
+	GranularityMinute:  time.Minute,
+	GranularityHour:    time.Hour,
+	GranularityDay:     24 * time.Hour,
+	GranularityWeek:    7 * 24 * time.Hour,
+	GranularityMonth:   28 * 24 * time.Hour,
+	GranularityQuarter: 90 * 24 * time.Hour,
+	GranularityYear:    365 * 24 * time.Hour,
+}
+
+// checkGranularity validates a granularity and the number of buckets it
+// would produce over [from, to]
+func checkGranularity(granularity string, from, to time.Time) error {
+	size, ok := nominalBucketSize[granularity]
+	if !ok {
+		return ErrUnknownGranularity
+	}
+	if to.Sub(from)/size+1 > maxVolumeBuckets {
+		return ErrTooManyBuckets
+	}
+	return nil
+}
+
+// usesDailyRollups reports whether buckets are whole UTC days or unions of
+// them, so that the daily_stats rollups can answer them. Days in any other
+// time zone straddle two UTC days.
+func usesDailyRollups(granularity string, loc *time.Location) bool {
+	if loc != time.UTC && loc.String() != "UTC" {
+		return false
+	}
+	return granularity != GranularityMinute && granularity != GranularityHour
+}
+
+// truncateToBucket returns the start of the bucket containing t, with
+// bucket boundaries taken in loc. It matches $dateTrunc with the same
+// timezone and startOfWeek set to Monday.
+func truncateToBucket(t time.Time, granularity string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch granularity {
+	case GranularityMinute:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc)
+	case GranularityHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+	case GranularityWeek:
+		// Weekday counts from Sunday, ISO weeks start on Monday
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, loc)
+	case GranularityMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	case GranularityQuarter:
+		month := time.Month((int(t.Month())-1)/3*3 + 1)
+		return time.Date(t.Year(), month, 1, 0, 0, 0, 0, loc)
+	case GranularityYear:
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+}
+
+// nextBucket returns the start of the bucket following the one starting at
+// start, with bucket boundaries taken in loc
+func nextBucket(start time.Time, granularity string, loc *time.Location) time.Time {
+	start = start.In(loc)
+	switch granularity {
+	case GranularityMinute:
+		return start.Add(time.Minute)
+	case GranularityHour:
+		return start.Add(time.Hour)
+	case GranularityWeek:
+		return start.AddDate(0, 0, 7)
+	case GranularityMonth:
+		return start.AddDate(0, 1, 0)
+	case GranularityQuarter:
+		return start.AddDate(0, 3, 0)
+	case GranularityYear:
+		return start.AddDate(1, 0, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
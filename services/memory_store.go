@@ -0,0 +1,527 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"admin_statistics_api/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryTransactionStore keeps transactions in a slice and computes every
+// statistic in Go. It mirrors MongoTransactionStore and is intended for
+// tests and local experiments.
+type MemoryTransactionStore struct {
+	mu           sync.RWMutex
+	transactions []models.Transaction
+
+	// rollups and rollupUsers stand in for the daily_stats and
+	// daily_stat_users collections. Like them they only change through
+	// RebuildDailyStats and AddToDailyStats, so they go stale when
+	// transactions are added without either.
+	rollups     map[dailyStatKey]models.DailyStat
+	rollupUsers map[dailyStatKey]map[primitive.ObjectID]bool
+	// counted maps each transaction in the rollups to its day, standing in
+	// for daily_stat_transactions
+	counted map[primitive.ObjectID]time.Time
+}
+
+// dailyStatKey is the unique key of a rollup
+type dailyStatKey struct {
+	day      time.Time
+	currency string
+	txType   string
+}
+
+// NewMemoryTransactionStore returns a store holding transactions with their
+// rollups built, as scripts/generate_data.go leaves a database
+func NewMemoryTransactionStore(transactions ...models.Transaction) *MemoryTransactionStore {
+	store := &MemoryTransactionStore{
+		rollups:     make(map[dailyStatKey]models.DailyStat),
+		rollupUsers: make(map[dailyStatKey]map[primitive.ObjectID]bool),
+		counted:     make(map[primitive.ObjectID]time.Time),
+	}
+	store.Add(transactions...)
+	store.RebuildDailyStats(context.Background(), time.Time{}, time.Date(9999, time.January, 1, 0, 0, 0, 0, time.UTC))
+	return store
+}
+
+// Add appends transactions to the store without any uniqueness checks,
+// assigning IDs to those that lack one. Like InsertTransactions it leaves
+// the rollups alone.
+func (s *MemoryTransactionStore) Add(transactions ...models.Transaction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tx := range transactions {
+		if tx.ID.IsZero() {
+			tx.ID = primitive.NewObjectID()
+		}
+		s.transactions = append(s.transactions, tx)
+	}
+}
+
+// inRange returns the transactions created within [from, to]
+func (s *MemoryTransactionStore) inRange(from, to time.Time) []models.Transaction {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matched []models.Transaction
+	for _, tx := range s.transactions {
+		if tx.CreatedAt.Before(from) || tx.CreatedAt.After(to) {
+			continue
+		}
+		matched = append(matched, tx)
+	}
+	return matched
+}
+
+func (s *MemoryTransactionStore) GrossGamingRevenue(ctx context.Context, from, to time.Time) ([]models.GrossGamingRevenue, error) {
+	byCurrency := make(map[string]*models.GrossGamingRevenue)
+	for _, tx := range s.inRange(from, to) {
+		ggr, ok := byCurrency[tx.Currency]
+		if !ok {
+			ggr = &models.GrossGamingRevenue{Currency: tx.Currency}
+			byCurrency[tx.Currency] = ggr
+		}
+
+		amount := models.DecimalFrom128(tx.Amount)
+		usdAmount := models.DecimalFrom128(tx.USDAmount)
+		switch tx.Type {
+		case "Wager":
+			ggr.Amount = ggr.Amount.Add(amount)
+			ggr.USDValue = ggr.USDValue.Add(usdAmount)
+		case "Payout":
+			ggr.Amount = ggr.Amount.Sub(amount)
+			ggr.USDValue = ggr.USDValue.Sub(usdAmount)
+		}
+	}
+
+	var results []models.GrossGamingRevenue
+	for _, ggr := range byCurrency {
+		results = append(results, *ggr)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Currency < results[j].Currency
+	})
+
+	return results, nil
+}
+
+func (s *MemoryTransactionStore) WagerVolume(ctx context.Context, from, to time.Time, granularity string, loc *time.Location) ([]models.WagerVolume, error) {
+	var volumes []models.WagerVolume
+	for _, tx := range s.inRange(from, to) {
+		if tx.Type != "Wager" {
+			continue
+		}
+		volumes = append(volumes, models.WagerVolume{
+			Bucket:   truncateToBucket(tx.CreatedAt, granularity, loc),
+			Currency: tx.Currency,
+			Amount:   models.DecimalFrom128(tx.Amount),
+			USDValue: models.DecimalFrom128(tx.USDAmount),
+		})
+	}
+
+	return mergeWagerVolume(volumes), nil
+}
+
+func (s *MemoryTransactionStore) UserWagerRank(ctx context.Context, userID primitive.ObjectID, from, to time.Time) (*UserWagerRank, int, error) {
+	byUser := make(map[primitive.ObjectID]models.Decimal)
+	for _, tx := range s.inRange(from, to) {
+		if tx.Type != "Wager" {
+			continue
+		}
+		byUser[tx.UserID] = byUser[tx.UserID].Add(models.DecimalFrom128(tx.USDAmount))
+	}
+
+	target, ok := byUser[userID]
+	if !ok {
+		return nil, len(byUser), nil
+	}
+
+	// Standard competition ranking: one plus the number of higher totals
+	rank := &UserWagerRank{TotalWageredUSD: target, Rank: 1}
+	for id, total := range byUser {
+		switch cmp := total.Cmp(target); {
+		case cmp > 0:
+			rank.Rank++
+		case cmp == 0 && id != userID:
+			rank.TiedUsers++
+		}
+	}
+
+	return rank, len(byUser), nil
+}
+
+func (s *MemoryTransactionStore) DailyStats(ctx context.Context, from, to time.Time) ([]models.DailyStat, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var stats []models.DailyStat
+	for key, stat := range s.rollups {
+		if !key.day.Before(from) && key.day.Before(to) {
+			stats = append(stats, stat)
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if !stats[i].Day.Equal(stats[j].Day) {
+			return stats[i].Day.Before(stats[j].Day)
+		}
+		if stats[i].Currency != stats[j].Currency {
+			return stats[i].Currency < stats[j].Currency
+		}
+		return stats[i].Type < stats[j].Type
+	})
+
+	return stats, nil
+}
+
+// RebuildDailyStats replaces the rollups of the UTC days in [from, to),
+// dropping those whose transactions are gone
+func (s *MemoryTransactionStore) RebuildDailyStats(ctx context.Context, from, to time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.rollups {
+		if !key.day.Before(from) && key.day.Before(to) {
+			delete(s.rollups, key)
+			delete(s.rollupUsers, key)
+		}
+	}
+	for id, day := range s.counted {
+		if !day.Before(from) && day.Before(to) {
+			delete(s.counted, id)
+		}
+	}
+
+	var txs []models.Transaction
+	for _, tx := range s.transactions {
+		if !tx.CreatedAt.Before(from) && tx.CreatedAt.Before(to) {
+			txs = append(txs, tx)
+		}
+	}
+	s.addToRollups(txs)
+	return nil
+}
+
+// AddToDailyStats increments the rollups of the transactions' days,
+// skipping transactions already counted and counting users new to a rollup
+// the way MongoTransactionStore does with daily_stat_users
+func (s *MemoryTransactionStore) AddToDailyStats(ctx context.Context, txs []models.Transaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var uncounted []models.Transaction
+	seen := make(map[primitive.ObjectID]bool)
+	for _, tx := range txs {
+		if _, ok := s.counted[tx.ID]; !ok && !seen[tx.ID] {
+			seen[tx.ID] = true
+			uncounted = append(uncounted, tx)
+		}
+	}
+	s.addToRollups(uncounted)
+	return nil
+}
+
+// addToRollups applies the deltas of txs and marks them counted; the
+// caller holds the lock
+func (s *MemoryTransactionStore) addToRollups(txs []models.Transaction) {
+	for _, tx := range txs {
+		s.counted[tx.ID] = truncateToDay(tx.CreatedAt)
+	}
+	for _, delta := range dailyStatDeltas(txs) {
+		key := dailyStatKey{day: delta.Day, currency: delta.Currency, txType: delta.Type}
+		stat, ok := s.rollups[key]
+		if !ok {
+			stat = models.DailyStat{Day: delta.Day, Currency: delta.Currency, Type: delta.Type}
+			s.rollupUsers[key] = make(map[primitive.ObjectID]bool)
+		}
+
+		stat.Count += delta.Count
+		stat.Amount = stat.Amount.Add(delta.Amount)
+		stat.USDAmount = stat.USDAmount.Add(delta.USDAmount)
+		for _, userID := range delta.users {
+			if !s.rollupUsers[key][userID] {
+				s.rollupUsers[key][userID] = true
+				stat.UserCount++
+			}
+		}
+		s.rollups[key] = stat
+	}
+}
+
+// InsertTransactions enforces the same uniqueness rules as the MongoDB
+// indexes on idempotencyKey and (roundId, type, roundSlot)
+func (s *MemoryTransactionStore) InsertTransactions(ctx context.Context, txs []models.Transaction) (map[int]error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type roundKey struct {
+		roundID   string
+		txType    string
+		roundSlot string
+	}
+
+	keys := make(map[string]bool)
+	rounds := make(map[roundKey]bool)
+	for _, tx := range s.transactions {
+		if tx.IdempotencyKey != "" {
+			keys[tx.IdempotencyKey] = true
+		}
+		rounds[roundKey{tx.RoundID, tx.Type, tx.RoundSlot}] = true
+	}
+
+	failed := make(map[int]error)
+	for i := range txs {
+		round := roundKey{txs[i].RoundID, txs[i].Type, txs[i].RoundSlot}
+		switch {
+		case txs[i].IdempotencyKey != "" && keys[txs[i].IdempotencyKey]:
+			failed[i] = ErrDuplicateIdempotencyKey
+			continue
+		case rounds[round]:
+			failed[i] = ErrDuplicateRoundTransaction
+			continue
+		}
+
+		if txs[i].ID.IsZero() {
+			txs[i].ID = primitive.NewObjectID()
+		}
+		if txs[i].IdempotencyKey != "" {
+			keys[txs[i].IdempotencyKey] = true
+		}
+		rounds[round] = true
+		s.transactions = append(s.transactions, txs[i])
+	}
+
+	if len(failed) == 0 {
+		return nil, nil
+	}
+	return failed, nil
+}
+
+func (s *MemoryTransactionStore) FindByIdempotencyKeys(ctx context.Context, keys []string) (map[string]models.Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		wanted[key] = true
+	}
+
+	found := make(map[string]models.Transaction)
+	for _, tx := range s.transactions {
+		if tx.IdempotencyKey != "" && wanted[tx.IdempotencyKey] {
+			found[tx.IdempotencyKey] = tx
+		}
+	}
+	return found, nil
+}
+
+func (s *MemoryTransactionStore) RoundTransactions(ctx context.Context, roundID string) ([]models.Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var txs []models.Transaction
+	for _, tx := range s.transactions {
+		if tx.RoundID == roundID {
+			txs = append(txs, tx)
+		}
+	}
+	sort.SliceStable(txs, func(i, j int) bool {
+		return txs[i].CreatedAt.Before(txs[j].CreatedAt)
+	})
+	return txs, nil
+}
+
+func (s *MemoryTransactionStore) UnsettledRounds(ctx context.Context, from, to time.Time, limit int) ([]RoundTransactions, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byRound := make(map[string][]models.Transaction)
+	touched := make(map[string]bool)
+	for _, tx := range s.transactions {
+		byRound[tx.RoundID] = append(byRound[tx.RoundID], tx)
+		if !tx.CreatedAt.Before(from) && !tx.CreatedAt.After(to) {
+			touched[tx.RoundID] = true
+		}
+	}
+
+	var rounds []RoundTransactions
+	for roundID := range touched {
+		txs := byRound[roundID]
+		if len(roundIssues(txs)) == 0 {
+			continue
+		}
+		sort.SliceStable(txs, func(i, j int) bool {
+			return txs[i].CreatedAt.Before(txs[j].CreatedAt)
+		})
+		rounds = append(rounds, RoundTransactions{RoundID: roundID, Transactions: txs})
+	}
+
+	sort.Slice(rounds, func(i, j int) bool {
+		first, second := rounds[i].Transactions[0].CreatedAt, rounds[j].Transactions[0].CreatedAt
+		if !first.Equal(second) {
+			return first.Before(second)
+		}
+		return rounds[i].RoundID < rounds[j].RoundID
+	})
+	if len(rounds) > limit {
+		rounds = rounds[:limit]
+	}
+
+	return rounds, nil
+}
+
+func (s *MemoryTransactionStore) UserCurrencySummaries(ctx context.Context, userID primitive.ObjectID, from, to time.Time) ([]models.UserCurrencySummary, error) {
+	byCurrency := make(map[string]*models.UserCurrencySummary)
+	rounds := make(map[string]map[string]bool)
+	for _, tx := range s.inRange(from, to) {
+		if tx.UserID != userID {
+			continue
+		}
+
+		summary, ok := byCurrency[tx.Currency]
+		if !ok {
+			summary = &models.UserCurrencySummary{
+				Currency:      tx.Currency,
+				FirstActivity: tx.CreatedAt,
+				LastActivity:  tx.CreatedAt,
+			}
+			byCurrency[tx.Currency] = summary
+			rounds[tx.Currency] = make(map[string]bool)
+		}
+		rounds[tx.Currency][tx.RoundID] = true
+
+		amount := models.DecimalFrom128(tx.Amount)
+		usdAmount := models.DecimalFrom128(tx.USDAmount)
+		switch tx.Type {
+		case "Wager":
+			summary.Wagered = summary.Wagered.Add(amount)
+			summary.WageredUSD = summary.WageredUSD.Add(usdAmount)
+			summary.WagerCount++
+		case "Payout":
+			summary.PaidOut = summary.PaidOut.Add(amount)
+			summary.PaidOutUSD = summary.PaidOutUSD.Add(usdAmount)
+			if amount.Cmp(summary.LargestWin) > 0 {
+				summary.LargestWin = amount
+			}
+			if usdAmount.Cmp(summary.LargestWinUSD) > 0 {
+				summary.LargestWinUSD = usdAmount
+			}
+		}
+		if tx.CreatedAt.Before(summary.FirstActivity) {
+			summary.FirstActivity = tx.CreatedAt
+		}
+		if tx.CreatedAt.After(summary.LastActivity) {
+			summary.LastActivity = tx.CreatedAt
+		}
+	}
+
+	var summaries []models.UserCurrencySummary
+	for currency, summary := range byCurrency {
+		summary.RoundCount = len(rounds[currency])
+		summary.NetGGR = summary.Wagered.Sub(summary.PaidOut)
+		summary.NetGGRUSD = summary.WageredUSD.Sub(summary.PaidOutUSD)
+		if summary.WagerCount > 0 {
+			summary.AverageBet = summary.Wagered.DivInt(int64(summary.WagerCount))
+		}
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Currency < summaries[j].Currency
+	})
+
+	return summaries, nil
+}
+
+func (s *MemoryTransactionStore) Leaderboard(ctx context.Context, query LeaderboardQuery) ([]LeaderboardRow, int, error) {
+	byUser := make(map[primitive.ObjectID]*LeaderboardRow)
+	rounds := make(map[primitive.ObjectID]map[string]bool)
+	for _, tx := range s.inRange(query.From, query.To) {
+		if query.Currency != "" && tx.Currency != query.Currency {
+			continue
+		}
+
+		row, ok := byUser[tx.UserID]
+		if !ok {
+			row = &LeaderboardRow{UserID: tx.UserID}
+			byUser[tx.UserID] = row
+		}
+		usdAmount := models.DecimalFrom128(tx.USDAmount)
+		switch tx.Type {
+		case "Wager":
+			row.WageredUSD = row.WageredUSD.Add(usdAmount)
+			if rounds[tx.UserID] == nil {
+				rounds[tx.UserID] = make(map[string]bool)
+			}
+			rounds[tx.UserID][tx.RoundID] = true
+		case "Payout":
+			row.PaidOutUSD = row.PaidOutUSD.Add(usdAmount)
+		}
+	}
+
+	rows := make([]LeaderboardRow, 0, len(byUser))
+	for _, row := range byUser {
+		row.NetLossUSD = row.WageredUSD.Sub(row.PaidOutUSD)
+		row.Rounds = len(rounds[row.UserID])
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if cmp := rows[i].metricValue(query.Metric).Cmp(rows[j].metricValue(query.Metric)); cmp != 0 {
+			return cmp > 0
+		}
+		return rows[i].UserID.Hex() < rows[j].UserID.Hex()
+	})
+
+	totalUsers := len(rows)
+	if len(rows) > query.Limit {
+		rows = rows[:query.Limit]
+	}
+	return rows, totalUsers, nil
+}
+
+func (s *MemoryTransactionStore) StreamTransactions(ctx context.Context, query TransactionQuery, fn func(models.Transaction) error) error {
+	var matched []models.Transaction
+	for _, tx := range s.inRange(query.From, query.To) {
+		if query.UserID != nil && tx.UserID != *query.UserID {
+			continue
+		}
+		if query.Currency != "" && tx.Currency != query.Currency {
+			continue
+		}
+		if query.Type != "" && tx.Type != query.Type {
+			continue
+		}
+		if query.After != nil && !positionAfter(tx, *query.After) {
+			continue
+		}
+		matched = append(matched, tx)
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.Before(matched[j].CreatedAt)
+		}
+		return matched[i].ID.Hex() < matched[j].ID.Hex()
+	})
+
+	for _, tx := range matched {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// positionAfter reports whether tx comes after pos in (createdAt, _id) order
+func positionAfter(tx models.Transaction, pos TransactionPosition) bool {
+	if !tx.CreatedAt.Equal(pos.CreatedAt) {
+		return tx.CreatedAt.After(pos.CreatedAt)
+	}
+	return tx.ID.Hex() > pos.ID.Hex()
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"admin_statistics_api/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoAPIKeyStore keeps API keys in the api_keys collection, one document
+// per key
+type MongoAPIKeyStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoAPIKeyStore(db *mongo.Database) *MongoAPIKeyStore {
+	return &MongoAPIKeyStore{
+		collection: db.Collection("api_keys"),
+	}
+}
+
+func (s *MongoAPIKeyStore) InsertKey(ctx context.Context, key models.APIKey) (models.APIKey, error) {
+	key.ID = primitive.NewObjectID()
+	if _, err := s.collection.InsertOne(ctx, key); err != nil {
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+func (s *MongoAPIKeyStore) ListKeys(ctx context.Context, owner string, includeRevoked bool) ([]models.APIKey, error) {
+	filter := bson.M{}
+	if owner != "" {
+		filter["owner"] = owner
+	}
+	if !includeRevoked {
+		filter["revokedAt"] = bson.M{"$exists": false}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := []models.APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *MongoAPIKeyStore) KeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	return s.findOne(ctx, bson.M{"hash": hash})
+}
+
+func (s *MongoAPIKeyStore) ReplaceSecret(ctx context.Context, id primitive.ObjectID, prefix, hash string, at time.Time) (models.APIKey, error) {
+	return s.update(ctx, bson.M{"_id": id, "revokedAt": bson.M{"$exists": false}}, bson.M{
+		"$set": bson.M{"prefix": prefix, "hash": hash, "rotatedAt": at},
+	})
+}
+
+func (s *MongoAPIKeyStore) RevokeKey(ctx context.Context, id primitive.ObjectID, at time.Time) (models.APIKey, error) {
+	return s.update(ctx, bson.M{"_id": id, "revokedAt": bson.M{"$exists": false}}, bson.M{
+		"$set": bson.M{"revokedAt": at},
+	})
+}
+
+func (s *MongoAPIKeyStore) TouchKey(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	_, err := s.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$max": bson.M{"lastUsedAt": at}})
+	return err
+}
+
+func (s *MongoAPIKeyStore) findOne(ctx context.Context, filter bson.M) (models.APIKey, error) {
+	var key models.APIKey
+	err := s.collection.FindOne(ctx, filter).Decode(&key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.APIKey{}, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+// update applies an update to the active key matched by filter, telling
+// a missing key apart from a revoked one
+func (s *MongoAPIKeyStore) update(ctx context.Context, filter, update bson.M) (models.APIKey, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var key models.APIKey
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if _, err := s.findOne(ctx, bson.M{"_id": filter["_id"]}); err != nil {
+			return models.APIKey{}, err
+		}
+		return models.APIKey{}, ErrAPIKeyRevoked
+	}
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
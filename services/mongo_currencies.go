@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+
+	"admin_statistics_api/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MongoCurrencySource reads the registry from the currencies collection,
+// one document per currency code
+type MongoCurrencySource struct {
+	collection *mongo.Collection
+}
+
+func NewMongoCurrencySource(db *mongo.Database) *MongoCurrencySource {
+	return &MongoCurrencySource{
+		collection: db.Collection("currencies"),
+	}
+}
+
+func (s *MongoCurrencySource) Currencies(ctx context.Context) ([]models.Currency, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	currencies := []models.Currency{}
+	if err := cursor.All(ctx, &currencies); err != nil {
+		return nil, err
+	}
+	return currencies, nil
+}
+
+// SeedIfEmpty inserts the given currencies when the collection has none,
+// so a new deployment starts with a usable registry. An existing registry
+// is never touched.
+func (s *MongoCurrencySource) SeedIfEmpty(ctx context.Context, currencies []models.Currency) (bool, error) {
+	count, err := s.collection.CountDocuments(ctx, bson.M{})
+	if err != nil || count > 0 {
+		return false, err
+	}
+
+	docs := make([]interface{}, len(currencies))
+	for i, currency := range currencies {
+		docs[i] = currency
+	}
+	if _, err := s.collection.InsertMany(ctx, docs); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,376 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"admin_statistics_api/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Feed modes accepted by NewMongoTransactionFeed
+const (
+	FeedModeAuto         = "auto"
+	FeedModeChangeStream = "change_stream"
+	FeedModePoll         = "poll"
+)
+
+// Error code returned by servers that cannot open change streams
+// (standalone mongod without a replica set)
+const changeStreamNotSupportedCode = 40573
+
+const (
+	changeStreamStateID = "transactions_change_stream"
+	pollStateID         = "transactions_poll"
+	feedBatchSize       = 1000
+)
+
+const (
+	// pollLag keeps polls behind the clock, so a transaction that commits
+	// shortly after its createdAt is visible before the watermark passes it
+	pollLag = 30 * time.Second
+	// pollOverlap is how far behind the watermark every poll scans again,
+	// catching transactions that committed more than pollLag after their
+	// createdAt. Those already handled are skipped by _id.
+	pollOverlap = 5 * time.Minute
+)
+
+// MongoTransactionFeed tails the transactions collection, either through a
+// change stream or, on deployments without a replica set, by polling for
+// documents created since a watermark. The resume token and watermark are
+// persisted in the rollup_state collection.
+type MongoTransactionFeed struct {
+	collection   *mongo.Collection
+	state        *mongo.Collection
+	mode         string
+	pollInterval time.Duration
+}
+
+func NewMongoTransactionFeed(db *mongo.Database, mode string, pollInterval time.Duration) *MongoTransactionFeed {
+	if mode == "" {
+		mode = FeedModeAuto
+	}
+	return &MongoTransactionFeed{
+		collection:   db.Collection("transactions"),
+		state:        db.Collection("rollup_state"),
+		mode:         mode,
+		pollInterval: pollInterval,
+	}
+}
+
+func (f *MongoTransactionFeed) Run(ctx context.Context, bootstrap func(ctx context.Context) error, handle func(ctx context.Context, changes TransactionChanges) error) error {
+	switch f.mode {
+	case FeedModePoll:
+		return f.poll(ctx, bootstrap, handle)
+	case FeedModeChangeStream:
+		return f.watch(ctx, bootstrap, handle)
+	case FeedModeAuto:
+		err := f.watch(ctx, bootstrap, handle)
+		var serverErr mongo.ServerError
+		if errors.As(err, &serverErr) && serverErr.HasErrorCode(changeStreamNotSupportedCode) {
+			log.Println("Change streams not supported, falling back to polling transactions")
+			f.mode = FeedModePoll
+			return f.poll(ctx, bootstrap, handle)
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown feed mode %q", f.mode)
+	}
+}
+
+// watch consumes a change stream, persisting the resume token after every
+// handled batch. Updated, replaced and deleted transactions have their days
+// rebuilt; the day of a deleted transaction is only known from its
+// pre-image, which the server records when changeStreamPreAndPostImages is
+// enabled on the collection. Without a saved resume token the stream is
+// opened first and the rollups are bootstrapped before its position is
+// saved, so changes made while the bootstrap runs are still delivered.
+func (f *MongoTransactionFeed) watch(ctx context.Context, bootstrap func(ctx context.Context) error, handle func(ctx context.Context, changes TransactionChanges) error) error {
+	opts := options.ChangeStream().
+		SetFullDocument(options.UpdateLookup).
+		SetFullDocumentBeforeChange(options.WhenAvailable)
+
+	var state struct {
+		ResumeToken bson.Raw `bson:"resumeToken"`
+	}
+	err := f.state.FindOne(ctx, bson.M{"_id": changeStreamStateID}).Decode(&state)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return fmt.Errorf("failed to load resume token: %v", err)
+	}
+	if state.ResumeToken != nil {
+		opts.SetResumeAfter(state.ResumeToken)
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"operationType": bson.M{"$in": bson.A{"insert", "update", "replace", "delete"}},
+		}}},
+	}
+
+	stream, err := f.collection.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+
+	if state.ResumeToken == nil {
+		// Inserts the bootstrap already counted are delivered again and
+		// skipped by AddToDailyStats
+		token := stream.ResumeToken()
+		if err := bootstrap(ctx); err != nil {
+			return err
+		}
+		if err := f.saveState(ctx, changeStreamStateID, bson.M{"resumeToken": token}); err != nil {
+			return err
+		}
+	}
+
+	for {
+		// Block for the first event, then drain whatever is already buffered
+		if !stream.Next(ctx) {
+			return stream.Err()
+		}
+
+		var changes TransactionChanges
+		for {
+			var event struct {
+				OperationType string `bson:"operationType"`
+				DocumentKey   struct {
+					ID primitive.ObjectID `bson:"_id"`
+				} `bson:"documentKey"`
+				FullDocument             *models.Transaction `bson:"fullDocument"`
+				FullDocumentBeforeChange *struct {
+					CreatedAt time.Time `bson:"createdAt"`
+				} `bson:"fullDocumentBeforeChange"`
+			}
+			if err := stream.Decode(&event); err != nil {
+				return err
+			}
+
+			switch {
+			case event.OperationType == "insert" && event.FullDocument != nil:
+				changes.Inserted = append(changes.Inserted, *event.FullDocument)
+			case event.OperationType != "insert":
+				// Both days are rebuilt in case createdAt itself changed
+				if event.FullDocumentBeforeChange != nil {
+					changes.Rebuild = append(changes.Rebuild, event.FullDocumentBeforeChange.CreatedAt)
+				}
+				if event.FullDocument != nil {
+					changes.Rebuild = append(changes.Rebuild, event.FullDocument.CreatedAt)
+				}
+				if event.FullDocumentBeforeChange == nil && event.FullDocument == nil {
+					log.Printf("Transaction %s was %sd without a pre-image, rebuild rollups with cmd/rollup",
+						event.DocumentKey.ID.Hex(), event.OperationType)
+				}
+			}
+
+			if len(changes.Inserted)+len(changes.Rebuild) >= feedBatchSize || !stream.TryNext(ctx) {
+				break
+			}
+		}
+		if err := stream.Err(); err != nil {
+			return err
+		}
+
+		if err := handle(ctx, changes); err != nil {
+			return err
+		}
+		if err := f.saveState(ctx, changeStreamStateID, bson.M{"resumeToken": stream.ResumeToken()}); err != nil {
+			return err
+		}
+	}
+}
+
+// pollState is the watermark of the poll fallback: every transaction
+// created up to LastCreatedAt has been handled, save those that commit
+// later than pollLag after it and are caught by the overlap
+type pollState struct {
+	LastCreatedAt time.Time `bson:"lastCreatedAt"`
+	// LastID is only read from watermarks saved before createdAt was
+	// tracked
+	LastID primitive.ObjectID `bson:"lastId"`
+}
+
+// poll repeatedly scans the transactions created between pollOverlap
+// before the watermark and pollLag before now, so client-assigned _ids and
+// slow commits are seen. It cannot see updates, deletes or transactions
+// stored with a createdAt further behind the watermark, such as backfills;
+// those need a rollup rebuild with cmd/rollup.
+func (f *MongoTransactionFeed) poll(ctx context.Context, bootstrap func(ctx context.Context) error, handle func(ctx context.Context, changes TransactionChanges) error) error {
+	state, err := f.loadPollState(ctx, bootstrap)
+	if err != nil {
+		return err
+	}
+
+	// handled holds the createdAt of the transactions inside the overlap
+	// window that were already handled, by _id. After a restart it starts
+	// empty and AddToDailyStats skips the window's transactions instead.
+	handled := make(map[primitive.ObjectID]time.Time)
+
+	ticker := time.NewTicker(f.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := f.scan(ctx, &state, handled, handle); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// scan passes the transactions created in [watermark - pollOverlap,
+// now - pollLag] that were not handled yet to handle in batches, then
+// advances the watermark to the end of the scan
+func (f *MongoTransactionFeed) scan(ctx context.Context, state *pollState, handled map[primitive.ObjectID]time.Time, handle func(ctx context.Context, changes TransactionChanges) error) error {
+	until := time.Now().UTC().Add(-pollLag)
+	if !until.After(state.LastCreatedAt) {
+		return nil
+	}
+
+	filter := bson.M{
+		"createdAt": bson.M{
+			"$gte": state.LastCreatedAt.Add(-pollOverlap),
+			"$lte": until,
+		},
+	}
+	opts := options.Find().
+		SetSort(bson.D{
+			{Key: "createdAt", Value: 1},
+			{Key: "_id", Value: 1},
+		}).
+		SetBatchSize(feedBatchSize)
+
+	cursor, err := f.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var batch []models.Transaction
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := handle(ctx, TransactionChanges{Inserted: batch}); err != nil {
+			return err
+		}
+		for _, tx := range batch {
+			handled[tx.ID] = tx.CreatedAt
+		}
+		// Everything up to the last transaction of the batch was scanned
+		if last := batch[len(batch)-1].CreatedAt; last.After(state.LastCreatedAt) {
+			state.LastCreatedAt = last
+			if err := f.saveState(ctx, pollStateID, bson.M{"lastCreatedAt": last}); err != nil {
+				return err
+			}
+		}
+		batch = nil
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var tx models.Transaction
+		if err := cursor.Decode(&tx); err != nil {
+			return err
+		}
+		if _, ok := handled[tx.ID]; ok {
+			continue
+		}
+		batch = append(batch, tx)
+		if len(batch) == feedBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	state.LastCreatedAt = until
+	if err := f.saveState(ctx, pollStateID, bson.M{"lastCreatedAt": until}); err != nil {
+		return err
+	}
+	for id, createdAt := range handled {
+		if createdAt.Before(until.Add(-pollOverlap)) {
+			delete(handled, id)
+		}
+	}
+	return nil
+}
+
+// loadPollState returns the persisted watermark. On first start the
+// watermark is taken before the rollups are bootstrapped and saved after,
+// so transactions stored while the bootstrap runs are still polled; those
+// it already counted are skipped by AddToDailyStats. A watermark saved
+// with only an _id takes the createdAt of that transaction.
+func (f *MongoTransactionFeed) loadPollState(ctx context.Context, bootstrap func(ctx context.Context) error) (pollState, error) {
+	var state pollState
+	err := f.state.FindOne(ctx, bson.M{"_id": pollStateID}).Decode(&state)
+	if err == mongo.ErrNoDocuments {
+		state = pollState{LastCreatedAt: time.Now().UTC().Add(-pollLag)}
+		if err := bootstrap(ctx); err != nil {
+			return state, err
+		}
+		if err := f.saveState(ctx, pollStateID, bson.M{"lastCreatedAt": state.LastCreatedAt}); err != nil {
+			return state, err
+		}
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to load watermark: %v", err)
+	}
+	// Watermarks saved before createdAt was tracked only hold an _id, and
+	// an all-zero one was saved while the collection was empty
+	if !state.LastCreatedAt.IsZero() || state.LastID.IsZero() {
+		return state, nil
+	}
+
+	var last struct {
+		CreatedAt time.Time `bson:"createdAt"`
+	}
+	opts := options.FindOne().SetProjection(bson.M{"createdAt": 1})
+	err = f.collection.FindOne(ctx, bson.M{"_id": state.LastID}, opts).Decode(&last)
+	if err == mongo.ErrNoDocuments {
+		// The transaction is gone, so resume from the newest one
+		opts.SetSort(bson.D{{Key: "createdAt", Value: -1}})
+		err = f.collection.FindOne(ctx, bson.M{}, opts).Decode(&last)
+	}
+	if err != nil && err != mongo.ErrNoDocuments {
+		return state, fmt.Errorf("failed to initialise watermark: %v", err)
+	}
+
+	state.LastCreatedAt = last.CreatedAt
+	if err := f.saveState(ctx, pollStateID, bson.M{"lastCreatedAt": state.LastCreatedAt}); err != nil {
+		return state, err
+	}
+	return state, nil
+}
+
+func (f *MongoTransactionFeed) saveState(ctx context.Context, id string, fields bson.M) error {
+	fields["updatedAt"] = time.Now().UTC()
+	_, err := f.state.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": fields},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save feed position: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"admin_statistics_api/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoRateProvider stores rate series in the exchange_rates collection,
+// one document per pair and EffectiveAt
+type MongoRateProvider struct {
+	collection *mongo.Collection
+}
+
+func NewMongoRateProvider(db *mongo.Database) *MongoRateProvider {
+	return &MongoRateProvider{
+		collection: db.Collection("exchange_rates"),
+	}
+}
+
+func (p *MongoRateProvider) RateAt(ctx context.Context, base, quote string, at time.Time) (models.ExchangeRate, error) {
+	filter := bson.M{
+		"base":        base,
+		"quote":       quote,
+		"effectiveAt": bson.M{"$lte": at},
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "effectiveAt", Value: -1}})
+
+	var rate models.ExchangeRate
+	err := p.collection.FindOne(ctx, filter, opts).Decode(&rate)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.ExchangeRate{}, ErrRateNotFound
+	}
+	if err != nil {
+		return models.ExchangeRate{}, err
+	}
+	return rate, nil
+}
+
+func (p *MongoRateProvider) Rates(ctx context.Context, base, quote string, from, to time.Time) ([]models.ExchangeRate, error) {
+	filter := bson.M{"base": base, "quote": quote}
+	effectiveAt := bson.M{}
+	if !from.IsZero() {
+		effectiveAt["$gte"] = from
+	}
+	if !to.IsZero() {
+		effectiveAt["$lte"] = to
+	}
+	if len(effectiveAt) > 0 {
+		filter["effectiveAt"] = effectiveAt
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "effectiveAt", Value: 1}}).
+		SetProjection(bson.M{"_id": 0})
+
+	cursor, err := p.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	results := []models.ExchangeRate{}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (p *MongoRateProvider) Series(ctx context.Context) ([]models.RateSeries, error) {
+	pipeline := []bson.M{
+		{"$sort": bson.D{{Key: "effectiveAt", Value: 1}}},
+		{
+			"$group": bson.M{
+				"_id": bson.M{
+					"base":  "$base",
+					"quote": "$quote",
+				},
+				"count":      bson.M{"$sum": 1},
+				"firstAt":    bson.M{"$first": "$effectiveAt"},
+				"lastAt":     bson.M{"$last": "$effectiveAt"},
+				"latestRate": bson.M{"$last": "$rate"},
+			},
+		},
+		{
+			"$project": bson.M{
+				"_id":        0,
+				"base":       "$_id.base",
+				"quote":      "$_id.quote",
+				"count":      1,
+				"firstAt":    1,
+				"lastAt":     1,
+				"latestRate": 1,
+			},
+		},
+	}
+
+	cursor, err := p.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	results := []models.RateSeries{}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	sortRateSeries(results)
+	return results, nil
+}
+
+func (p *MongoRateProvider) SaveRates(ctx context.Context, rates []models.ExchangeRate) (int, int, error) {
+	if len(rates) == 0 {
+		return 0, 0, nil
+	}
+
+	writes := make([]mongo.WriteModel, len(rates))
+	for i, rate := range rates {
+		writes[i] = mongo.NewReplaceOneModel().
+			SetFilter(bson.M{
+				"base":        rate.Base,
+				"quote":       rate.Quote,
+				"effectiveAt": rate.EffectiveAt,
+			}).
+			SetReplacement(rate).
+			SetUpsert(true)
+	}
+
+	result, err := p.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(result.UpsertedCount), int(result.MatchedCount), nil
+}
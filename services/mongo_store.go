@@ -0,0 +1,1093 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"admin_statistics_api/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoTransactionStore answers statistics queries with aggregation
+// pipelines over the transactions collection
+type MongoTransactionStore struct {
+	collection *mongo.Collection
+	dailyStats *mongo.Collection
+	// dailyStatUsers holds one document per user seen in a rollup, so
+	// incremental updates know which users are new to the day
+	dailyStatUsers *mongo.Collection
+	// dailyStatTransactions holds one document per transaction counted in
+	// the rollups, so no transaction is counted twice
+	dailyStatTransactions *mongo.Collection
+}
+
+func NewMongoTransactionStore(db *mongo.Database) *MongoTransactionStore {
+	return &MongoTransactionStore{
+		collection:            db.Collection("transactions"),
+		dailyStats:            db.Collection("daily_stats"),
+		dailyStatUsers:        db.Collection("daily_stat_users"),
+		dailyStatTransactions: db.Collection("daily_stat_transactions"),
+	}
+}
+
+func (s *MongoTransactionStore) GrossGamingRevenue(ctx context.Context, from, to time.Time) ([]models.GrossGamingRevenue, error) {
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				"createdAt": bson.M{
+					"$gte": from,
+					"$lte": to,
+				},
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id": bson.M{
+					"currency": "$currency",
+					"type":     "$type",
+				},
+				"totalAmount":    bson.M{"$sum": bson.M{"$toDecimal": "$amount"}},
+				"totalUSDAmount": bson.M{"$sum": bson.M{"$toDecimal": "$usdAmount"}},
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id": "$_id.currency",
+				"wagers": bson.M{
+					"$sum": bson.M{
+						"$cond": bson.A{
+							bson.M{"$eq": bson.A{"$_id.type", "Wager"}},
+							"$totalAmount",
+							0,
+						},
+					},
+				},
+				"payouts": bson.M{
+					"$sum": bson.M{
+						"$cond": bson.A{
+							bson.M{"$eq": bson.A{"$_id.type", "Payout"}},
+							"$totalAmount",
+							0,
+						},
+					},
+				},
+				"wagersUSD": bson.M{
+					"$sum": bson.M{
+						"$cond": bson.A{
+							bson.M{"$eq": bson.A{"$_id.type", "Wager"}},
+							"$totalUSDAmount",
+							0,
+						},
+					},
+				},
+				"payoutsUSD": bson.M{
+					"$sum": bson.M{
+						"$cond": bson.A{
+							bson.M{"$eq": bson.A{"$_id.type", "Payout"}},
+							"$totalUSDAmount",
+							0,
+						},
+					},
+				},
+			},
+		},
+		{
+			"$project": bson.M{
+				"currency": "$_id",
+				"ggr":      bson.M{"$subtract": bson.A{"$wagers", "$payouts"}},
+				"ggrUSD":   bson.M{"$subtract": bson.A{"$wagersUSD", "$payoutsUSD"}},
+			},
+		},
+		{
+			"$sort": bson.M{
+				"currency": 1,
+			},
+		},
+	}
+
+	cursor, err := s.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []models.GrossGamingRevenue
+	for cursor.Next(ctx) {
+		var doc struct {
+			Currency string         `bson:"currency"`
+			GGR      models.Decimal `bson:"ggr"`
+			GGRUSD   models.Decimal `bson:"ggrUSD"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		results = append(results, models.GrossGamingRevenue{
+			Currency: doc.Currency,
+			Amount:   doc.GGR,
+			USDValue: doc.GGRUSD,
+		})
+	}
+
+	return results, cursor.Err()
+}
+
+func (s *MongoTransactionStore) WagerVolume(ctx context.Context, from, to time.Time, granularity string, loc *time.Location) ([]models.WagerVolume, error) {
+	bucket := bson.M{
+		"date":     "$createdAt",
+		"unit":     granularity,
+		"timezone": loc.String(),
+	}
+	if granularity == GranularityWeek {
+		bucket["startOfWeek"] = "monday"
+	}
+
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				"createdAt": bson.M{
+					"$gte": from,
+					"$lte": to,
+				},
+				"type": "Wager",
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id": bson.M{
+					"bucket":   bson.M{"$dateTrunc": bucket},
+					"currency": "$currency",
+				},
+				"totalAmount":    bson.M{"$sum": bson.M{"$toDecimal": "$amount"}},
+				"totalUSDAmount": bson.M{"$sum": bson.M{"$toDecimal": "$usdAmount"}},
+			},
+		},
+		{
+			"$project": bson.M{
+				"bucket":   "$_id.bucket",
+				"currency": "$_id.currency",
+				"amount":   "$totalAmount",
+				"usdValue": "$totalUSDAmount",
+			},
+		},
+		{
+			"$sort": bson.D{
+				{Key: "bucket", Value: 1},
+				{Key: "currency", Value: 1},
+			},
+		},
+	}
+
+	cursor, err := s.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []models.WagerVolume
+	for cursor.Next(ctx) {
+		var doc struct {
+			Bucket   time.Time      `bson:"bucket"`
+			Currency string         `bson:"currency"`
+			Amount   models.Decimal `bson:"amount"`
+			USDValue models.Decimal `bson:"usdValue"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		results = append(results, models.WagerVolume{
+			Bucket:   doc.Bucket.UTC(),
+			Currency: doc.Currency,
+			Amount:   doc.Amount,
+			USDValue: doc.USDValue,
+		})
+	}
+
+	return results, cursor.Err()
+}
+
+func (s *MongoTransactionStore) UserWagerRank(ctx context.Context, userID primitive.ObjectID, from, to time.Time) (*UserWagerRank, int, error) {
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				"createdAt": bson.M{
+					"$gte": from,
+					"$lte": to,
+				},
+				"type": "Wager",
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id":             "$userId",
+				"totalWageredUSD": bson.M{"$sum": bson.M{"$toDecimal": "$usdAmount"}},
+			},
+		},
+		{
+			"$facet": bson.M{
+				"total": bson.A{
+					bson.M{"$count": "users"},
+				},
+				"target": bson.A{
+					// $rank gives tied totals the same rank and skips the
+					// following ranks (standard competition ranking)
+					bson.M{
+						"$setWindowFields": bson.M{
+							"sortBy": bson.M{"totalWageredUSD": -1},
+							"output": bson.M{
+								"rank": bson.M{"$rank": bson.M{}},
+							},
+						},
+					},
+					bson.M{
+						"$setWindowFields": bson.M{
+							"partitionBy": "$totalWageredUSD",
+							"output": bson.M{
+								"sameTotal": bson.M{"$count": bson.M{}},
+							},
+						},
+					},
+					bson.M{"$match": bson.M{"_id": userID}},
+					bson.M{
+						"$project": bson.M{
+							"totalWageredUSD": 1,
+							"rank":            1,
+							"tiedUsers":       bson.M{"$subtract": bson.A{"$sameTotal", 1}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	cursor, err := s.collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result struct {
+		Total []struct {
+			Users int `bson:"users"`
+		} `bson:"total"`
+		Target []UserWagerRank `bson:"target"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&result); err != nil {
+			return nil, 0, err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	totalUsers := 0
+	if len(result.Total) > 0 {
+		totalUsers = result.Total[0].Users
+	}
+	if len(result.Target) == 0 {
+		return nil, totalUsers, nil
+	}
+
+	return &result.Target[0], totalUsers, nil
+}
+
+func (s *MongoTransactionStore) DailyStats(ctx context.Context, from, to time.Time) ([]models.DailyStat, error) {
+	filter := bson.M{
+		"day": bson.M{
+			"$gte": from,
+			"$lt":  to,
+		},
+	}
+	opts := options.Find().SetSort(bson.D{
+		{Key: "day", Value: 1},
+		{Key: "currency", Value: 1},
+		{Key: "type", Value: 1},
+	})
+
+	cursor, err := s.dailyStats.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var stats []models.DailyStat
+	if err := cursor.All(ctx, &stats); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+func (s *MongoTransactionStore) RebuildDailyStats(ctx context.Context, from, to time.Time) error {
+	// Every rollup written by this rebuild is tagged so that (day, currency,
+	// type) combinations which no longer have transactions can be removed
+	// afterwards without leaving a window where the range looks empty
+	rebuildID := primitive.NewObjectID()
+
+	if err := s.rebuildDailyStatTransactions(ctx, from, to, rebuildID); err != nil {
+		return err
+	}
+
+	pipeline := append(s.countedTransactions(from, to, rebuildID), []bson.M{
+		{
+			"$group": bson.M{
+				// An ordered document keeps the group key stable between runs
+				"_id": bson.D{
+					{Key: "day", Value: bson.M{
+						"$dateTrunc": bson.M{
+							"date": "$createdAt",
+							"unit": "day",
+						},
+					}},
+					{Key: "currency", Value: "$currency"},
+					{Key: "type", Value: "$type"},
+				},
+				"count":     bson.M{"$sum": 1},
+				"amount":    bson.M{"$sum": bson.M{"$toDecimal": "$amount"}},
+				"usdAmount": bson.M{"$sum": bson.M{"$toDecimal": "$usdAmount"}},
+				"users":     bson.M{"$addToSet": "$userId"},
+			},
+		},
+		{
+			"$project": bson.M{
+				"_id":       0,
+				"day":       "$_id.day",
+				"currency":  "$_id.currency",
+				"type":      "$_id.type",
+				"count":     1,
+				"amount":    1,
+				"usdAmount": 1,
+				"userCount": bson.M{"$size": "$users"},
+				"updatedAt": "$$NOW",
+				"rebuildId": rebuildID,
+			},
+		},
+		{
+			// Rollups are matched on their unique (day, currency, type) key,
+			// so a rebuild replaces each row in place
+			"$merge": bson.M{
+				"into":           s.dailyStats.Name(),
+				"on":             bson.A{"day", "currency", "type"},
+				"whenMatched":    "replace",
+				"whenNotMatched": "insert",
+			},
+		},
+	}...)
+
+	cursor, err := s.collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return fmt.Errorf("failed to rebuild daily stats: %v", err)
+	}
+	cursor.Close(ctx)
+
+	_, err = s.dailyStats.DeleteMany(ctx, bson.M{
+		"day": bson.M{
+			"$gte": from,
+			"$lt":  to,
+		},
+		"rebuildId": bson.M{"$ne": rebuildID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove stale daily stats: %v", err)
+	}
+
+	return s.rebuildDailyStatUsers(ctx, from, to, rebuildID)
+}
+
+// rebuildDailyStatTransactions records the transactions created in the UTC
+// days in [from, to) as counted by the rebuild tagged rebuildID, and
+// forgets those of the range that are gone
+func (s *MongoTransactionStore) rebuildDailyStatTransactions(ctx context.Context, from, to time.Time, rebuildID primitive.ObjectID) error {
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				"createdAt": bson.M{
+					"$gte": from,
+					"$lt":  to,
+				},
+			},
+		},
+		{
+			"$project": bson.M{
+				"_id": 1,
+				"day": bson.M{
+					"$dateTrunc": bson.M{
+						"date": "$createdAt",
+						"unit": "day",
+					},
+				},
+				"rebuildId": rebuildID,
+			},
+		},
+		{
+			"$merge": bson.M{
+				"into":           s.dailyStatTransactions.Name(),
+				"on":             "_id",
+				"whenMatched":    "replace",
+				"whenNotMatched": "insert",
+			},
+		},
+	}
+
+	cursor, err := s.collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return fmt.Errorf("failed to rebuild daily stat transactions: %v", err)
+	}
+	cursor.Close(ctx)
+
+	_, err = s.dailyStatTransactions.DeleteMany(ctx, bson.M{
+		"day": bson.M{
+			"$gte": from,
+			"$lt":  to,
+		},
+		"rebuildId": bson.M{"$ne": rebuildID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove stale daily stat transactions: %v", err)
+	}
+
+	return nil
+}
+
+// countedTransactions matches the transactions of the UTC days in
+// [from, to) that the rebuild tagged rebuildID recorded in
+// daily_stat_transactions. Transactions committed after that step are left
+// to AddToDailyStats, which would otherwise count them a second time when
+// the feed delivers them.
+func (s *MongoTransactionStore) countedTransactions(from, to time.Time, rebuildID primitive.ObjectID) []bson.M {
+	return []bson.M{
+		{
+			"$match": bson.M{
+				"createdAt": bson.M{
+					"$gte": from,
+					"$lt":  to,
+				},
+			},
+		},
+		{
+			"$lookup": bson.M{
+				"from":         s.dailyStatTransactions.Name(),
+				"localField":   "_id",
+				"foreignField": "_id",
+				"as":           "counted",
+			},
+		},
+		{
+			"$match": bson.M{"counted.rebuildId": rebuildID},
+		},
+	}
+}
+
+// rebuildDailyStatUsers recomputes the users seen in each rollup for the
+// UTC days in [from, to), tagged the same way as the rollups themselves
+func (s *MongoTransactionStore) rebuildDailyStatUsers(ctx context.Context, from, to time.Time, rebuildID primitive.ObjectID) error {
+	pipeline := append(s.countedTransactions(from, to, rebuildID), []bson.M{
+		{
+			"$group": bson.M{
+				"_id": bson.D{
+					{Key: "day", Value: bson.M{
+						"$dateTrunc": bson.M{
+							"date": "$createdAt",
+							"unit": "day",
+						},
+					}},
+					{Key: "currency", Value: "$currency"},
+					{Key: "type", Value: "$type"},
+					{Key: "userId", Value: "$userId"},
+				},
+			},
+		},
+		{
+			"$project": bson.M{
+				"_id":       0,
+				"day":       "$_id.day",
+				"currency":  "$_id.currency",
+				"type":      "$_id.type",
+				"userId":    "$_id.userId",
+				"rebuildId": rebuildID,
+			},
+		},
+		{
+			"$merge": bson.M{
+				"into":           s.dailyStatUsers.Name(),
+				"on":             bson.A{"day", "currency", "type", "userId"},
+				"whenMatched":    "replace",
+				"whenNotMatched": "insert",
+			},
+		},
+	}...)
+
+	cursor, err := s.collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return fmt.Errorf("failed to rebuild daily stat users: %v", err)
+	}
+	cursor.Close(ctx)
+
+	_, err = s.dailyStatUsers.DeleteMany(ctx, bson.M{
+		"day": bson.M{
+			"$gte": from,
+			"$lt":  to,
+		},
+		"rebuildId": bson.M{"$ne": rebuildID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove stale daily stat users: %v", err)
+	}
+
+	return nil
+}
+
+// AddToDailyStats increments the rollups touched by txs in three unordered
+// bulk writes: the transactions are recorded first so that only those not
+// yet counted are added, then users new to a rollup so that userCount only
+// grows by them, then count, amount, usdAmount and userCount are
+// incremented, creating rollups that do not exist yet
+func (s *MongoTransactionStore) AddToDailyStats(ctx context.Context, txs []models.Transaction) error {
+	uncounted, err := s.recordDailyStatTransactions(ctx, txs)
+	if err != nil {
+		return err
+	}
+	deltas := dailyStatDeltas(uncounted)
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	var userWrites []mongo.WriteModel
+	var userDelta []int
+	for i, delta := range deltas {
+		for _, userID := range delta.users {
+			userWrites = append(userWrites, mongo.NewUpdateOneModel().
+				SetFilter(bson.D{
+					{Key: "day", Value: delta.Day},
+					{Key: "currency", Value: delta.Currency},
+					{Key: "type", Value: delta.Type},
+					{Key: "userId", Value: userID},
+				}).
+				SetUpdate(bson.M{"$setOnInsert": bson.M{"userId": userID}}).
+				SetUpsert(true))
+			userDelta = append(userDelta, i)
+		}
+	}
+
+	result, err := s.dailyStatUsers.BulkWrite(ctx, userWrites, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return fmt.Errorf("failed to record daily stat users: %v", err)
+	}
+	newUsers := make([]int64, len(deltas))
+	for index := range result.UpsertedIDs {
+		newUsers[userDelta[index]]++
+	}
+
+	now := time.Now().UTC()
+	statWrites := make([]mongo.WriteModel, len(deltas))
+	for i, delta := range deltas {
+		statWrites[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.D{
+				{Key: "day", Value: delta.Day},
+				{Key: "currency", Value: delta.Currency},
+				{Key: "type", Value: delta.Type},
+			}).
+			SetUpdate(bson.M{
+				"$inc": bson.M{
+					"count":     delta.Count,
+					"amount":    delta.Amount,
+					"usdAmount": delta.USDAmount,
+					"userCount": newUsers[i],
+				},
+				"$set": bson.M{"updatedAt": now},
+			}).
+			SetUpsert(true)
+	}
+
+	if _, err := s.dailyStats.BulkWrite(ctx, statWrites, options.BulkWrite().SetOrdered(false)); err != nil {
+		return fmt.Errorf("failed to update daily stats: %v", err)
+	}
+	return nil
+}
+
+// recordDailyStatTransactions records txs in daily_stat_transactions and
+// returns those that were not there yet, each at most once
+func (s *MongoTransactionStore) recordDailyStatTransactions(ctx context.Context, txs []models.Transaction) ([]models.Transaction, error) {
+	var unique []models.Transaction
+	seen := make(map[primitive.ObjectID]bool)
+	for _, tx := range txs {
+		if !seen[tx.ID] {
+			seen[tx.ID] = true
+			unique = append(unique, tx)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, nil
+	}
+
+	writes := make([]mongo.WriteModel, len(unique))
+	for i, tx := range unique {
+		writes[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": tx.ID}).
+			SetUpdate(bson.M{"$setOnInsert": bson.M{"day": truncateToDay(tx.CreatedAt)}}).
+			SetUpsert(true)
+	}
+
+	result, err := s.dailyStatTransactions.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record daily stat transactions: %v", err)
+	}
+
+	uncounted := make([]models.Transaction, 0, len(result.UpsertedIDs))
+	for index := range result.UpsertedIDs {
+		uncounted = append(uncounted, unique[index])
+	}
+	return uncounted, nil
+}
+
+func (s *MongoTransactionStore) InsertTransactions(ctx context.Context, txs []models.Transaction) (map[int]error, error) {
+	if len(txs) == 0 {
+		return nil, nil
+	}
+
+	docs := make([]interface{}, len(txs))
+	for i := range txs {
+		docs[i] = txs[i]
+	}
+
+	_, err := s.collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if err == nil {
+		return nil, nil
+	}
+
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return nil, err
+	}
+
+	failed := make(map[int]error, len(bulkErr.WriteErrors))
+	for _, writeErr := range bulkErr.WriteErrors {
+		failed[writeErr.Index] = classifyWriteError(writeErr)
+	}
+	return failed, nil
+}
+
+// classifyWriteError maps unique index violations to the store's
+// duplicate errors. The duplicate key message names the offending fields.
+func classifyWriteError(writeErr mongo.BulkWriteError) error {
+	if !mongo.IsDuplicateKeyError(writeErr.WriteError) {
+		return writeErr
+	}
+	switch {
+	case strings.Contains(writeErr.Message, "idempotencyKey"):
+		return ErrDuplicateIdempotencyKey
+	case strings.Contains(writeErr.Message, "roundId"):
+		return ErrDuplicateRoundTransaction
+	default:
+		return writeErr
+	}
+}
+
+func (s *MongoTransactionStore) FindByIdempotencyKeys(ctx context.Context, keys []string) (map[string]models.Transaction, error) {
+	found := make(map[string]models.Transaction)
+	if len(keys) == 0 {
+		return found, nil
+	}
+
+	cursor, err := s.collection.Find(ctx, bson.M{"idempotencyKey": bson.M{"$in": keys}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var tx models.Transaction
+		if err := cursor.Decode(&tx); err != nil {
+			return nil, err
+		}
+		found[tx.IdempotencyKey] = tx
+	}
+
+	return found, cursor.Err()
+}
+
+func (s *MongoTransactionStore) RoundTransactions(ctx context.Context, roundID string) ([]models.Transaction, error) {
+	opts := options.Find().SetSort(bson.D{
+		{Key: "createdAt", Value: 1},
+		{Key: "_id", Value: 1},
+	})
+
+	cursor, err := s.collection.Find(ctx, bson.M{"roundId": roundID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var txs []models.Transaction
+	if err := cursor.All(ctx, &txs); err != nil {
+		return nil, err
+	}
+
+	return txs, nil
+}
+
+func (s *MongoTransactionStore) UnsettledRounds(ctx context.Context, from, to time.Time, limit int) ([]RoundTransactions, error) {
+	// A round is suspect if it does not have exactly one wager, has no
+	// payout, or mixes currencies or users
+	suspect := bson.M{
+		"$expr": bson.M{
+			"$or": bson.A{
+				bson.M{"$ne": bson.A{"$wagers", 1}},
+				bson.M{"$eq": bson.A{"$payouts", 0}},
+				bson.M{"$gt": bson.A{bson.M{"$size": "$currencies"}, 1}},
+				bson.M{"$gt": bson.A{bson.M{"$size": "$users"}, 1}},
+			},
+		},
+	}
+	countType := func(txType string) bson.M {
+		return bson.M{
+			"$size": bson.M{
+				"$filter": bson.M{
+					"input": "$transactions",
+					"cond":  bson.M{"$eq": bson.A{"$$this.type", txType}},
+				},
+			},
+		}
+	}
+
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				"createdAt": bson.M{
+					"$gte": from,
+					"$lte": to,
+				},
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id": "$roundId",
+				"wagers": bson.M{
+					"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$type", "Wager"}}, 1, 0}},
+				},
+				"payouts": bson.M{
+					"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$type", "Payout"}}, 1, 0}},
+				},
+				"currencies": bson.M{"$addToSet": "$currency"},
+				"users":      bson.M{"$addToSet": "$userId"},
+				"firstAt":    bson.M{"$min": "$createdAt"},
+			},
+		},
+		// Rounds that look incomplete inside the range may be settled by
+		// transactions outside it, so re-check them against the full round
+		{"$match": suspect},
+		{
+			"$lookup": bson.M{
+				"from":         s.collection.Name(),
+				"localField":   "_id",
+				"foreignField": "roundId",
+				"as":           "transactions",
+			},
+		},
+		{
+			"$set": bson.M{
+				"wagers":     countType("Wager"),
+				"payouts":    countType("Payout"),
+				"currencies": bson.M{"$setUnion": bson.A{"$transactions.currency", bson.A{}}},
+				"users":      bson.M{"$setUnion": bson.A{"$transactions.userId", bson.A{}}},
+				"firstAt":    bson.M{"$min": "$transactions.createdAt"},
+			},
+		},
+		{"$match": suspect},
+		{
+			"$sort": bson.D{
+				{Key: "firstAt", Value: 1},
+				{Key: "_id", Value: 1},
+			},
+		},
+		{"$limit": limit},
+		{
+			"$project": bson.M{
+				"transactions": bson.M{
+					"$sortArray": bson.M{
+						"input":  "$transactions",
+						"sortBy": bson.M{"createdAt": 1},
+					},
+				},
+			},
+		},
+	}
+
+	cursor, err := s.collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rounds []RoundTransactions
+	if err := cursor.All(ctx, &rounds); err != nil {
+		return nil, err
+	}
+
+	return rounds, nil
+}
+
+func (s *MongoTransactionStore) UserCurrencySummaries(ctx context.Context, userID primitive.ObjectID, from, to time.Time) ([]models.UserCurrencySummary, error) {
+	sumIf := func(txType, field string) bson.M {
+		return bson.M{
+			"$sum": bson.M{
+				"$cond": bson.A{
+					bson.M{"$eq": bson.A{"$type", txType}},
+					bson.M{"$toDecimal": field},
+					0,
+				},
+			},
+		}
+	}
+	maxPayout := func(field string) bson.M {
+		return bson.M{
+			"$max": bson.M{
+				"$cond": bson.A{
+					bson.M{"$eq": bson.A{"$type", "Payout"}},
+					bson.M{"$toDecimal": field},
+					0,
+				},
+			},
+		}
+	}
+
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				"userId": userID,
+				"createdAt": bson.M{
+					"$gte": from,
+					"$lte": to,
+				},
+			},
+		},
+		// Group per round first so rounds can be counted without
+		// collecting every roundId into an array
+		{
+			"$group": bson.M{
+				"_id": bson.M{
+					"currency": "$currency",
+					"roundId":  "$roundId",
+				},
+				"wagered":    sumIf("Wager", "$amount"),
+				"wageredUSD": sumIf("Wager", "$usdAmount"),
+				"paidOut":    sumIf("Payout", "$amount"),
+				"paidOutUSD": sumIf("Payout", "$usdAmount"),
+				"wagerCount": bson.M{
+					"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$type", "Wager"}}, 1, 0}},
+				},
+				"largestWin":    maxPayout("$amount"),
+				"largestWinUSD": maxPayout("$usdAmount"),
+				"firstActivity": bson.M{"$min": "$createdAt"},
+				"lastActivity":  bson.M{"$max": "$createdAt"},
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id":           "$_id.currency",
+				"wagered":       bson.M{"$sum": "$wagered"},
+				"wageredUSD":    bson.M{"$sum": "$wageredUSD"},
+				"paidOut":       bson.M{"$sum": "$paidOut"},
+				"paidOutUSD":    bson.M{"$sum": "$paidOutUSD"},
+				"roundCount":    bson.M{"$sum": 1},
+				"wagerCount":    bson.M{"$sum": "$wagerCount"},
+				"largestWin":    bson.M{"$max": "$largestWin"},
+				"largestWinUSD": bson.M{"$max": "$largestWinUSD"},
+				"firstActivity": bson.M{"$min": "$firstActivity"},
+				"lastActivity":  bson.M{"$max": "$lastActivity"},
+			},
+		},
+		{
+			"$set": bson.M{
+				"currency":  "$_id",
+				"netGGR":    bson.M{"$subtract": bson.A{"$wagered", "$paidOut"}},
+				"netGGRUSD": bson.M{"$subtract": bson.A{"$wageredUSD", "$paidOutUSD"}},
+				"averageBet": bson.M{
+					"$cond": bson.A{
+						bson.M{"$gt": bson.A{"$wagerCount", 0}},
+						bson.M{"$divide": bson.A{"$wagered", "$wagerCount"}},
+						0,
+					},
+				},
+			},
+		},
+		{
+			"$sort": bson.M{
+				"currency": 1,
+			},
+		},
+	}
+
+	cursor, err := s.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var summaries []models.UserCurrencySummary
+	if err := cursor.All(ctx, &summaries); err != nil {
+		return nil, err
+	}
+
+	return summaries, nil
+}
+
+// leaderboardFields maps leaderboard metrics to the grouped field they sort by
+var leaderboardFields = map[string]string{
+	MetricWagered: "wageredUSD",
+	MetricWon:     "paidOutUSD",
+	MetricNetLoss: "netLossUSD",
+	MetricRounds:  "rounds",
+}
+
+func (s *MongoTransactionStore) Leaderboard(ctx context.Context, query LeaderboardQuery) ([]LeaderboardRow, int, error) {
+	sortField, ok := leaderboardFields[query.Metric]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown leaderboard metric %q", query.Metric)
+	}
+
+	match := bson.M{
+		"createdAt": bson.M{
+			"$gte": query.From,
+			"$lte": query.To,
+		},
+	}
+	if query.Currency != "" {
+		match["currency"] = query.Currency
+	}
+
+	sumIf := func(txType string) bson.M {
+		return bson.M{
+			"$sum": bson.M{
+				"$cond": bson.A{
+					bson.M{"$eq": bson.A{"$type", txType}},
+					bson.M{"$toDecimal": "$usdAmount"},
+					0,
+				},
+			},
+		}
+	}
+
+	pipeline := []bson.M{
+		{"$match": match},
+		{
+			"$group": bson.M{
+				"_id":        "$userId",
+				"wageredUSD": sumIf("Wager"),
+				"paidOutUSD": sumIf("Payout"),
+				// A round with several wagers is still one round
+				"roundIds": bson.M{
+					"$addToSet": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$type", "Wager"}}, "$roundId", "$$REMOVE"}},
+				},
+			},
+		},
+		{
+			"$set": bson.M{
+				"netLossUSD": bson.M{"$subtract": bson.A{"$wageredUSD", "$paidOutUSD"}},
+				"rounds":     bson.M{"$size": "$roundIds"},
+			},
+		},
+		{"$unset": "roundIds"},
+		// Only the top rows leave the server; the total is counted alongside
+		{
+			"$facet": bson.M{
+				"total": bson.A{
+					bson.M{"$count": "users"},
+				},
+				"top": bson.A{
+					bson.M{"$sort": bson.D{
+						{Key: sortField, Value: -1},
+						{Key: "_id", Value: 1},
+					}},
+					bson.M{"$limit": query.Limit},
+				},
+			},
+		},
+	}
+
+	cursor, err := s.collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result struct {
+		Total []struct {
+			Users int `bson:"users"`
+		} `bson:"total"`
+		Top []LeaderboardRow `bson:"top"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&result); err != nil {
+			return nil, 0, err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	totalUsers := 0
+	if len(result.Total) > 0 {
+		totalUsers = result.Total[0].Users
+	}
+
+	return result.Top, totalUsers, nil
+}
+
+func (s *MongoTransactionStore) StreamTransactions(ctx context.Context, query TransactionQuery, fn func(models.Transaction) error) error {
+	filter := bson.M{
+		"createdAt": bson.M{
+			"$gte": query.From,
+			"$lte": query.To,
+		},
+	}
+	if query.UserID != nil {
+		filter["userId"] = *query.UserID
+	}
+	if query.Currency != "" {
+		filter["currency"] = query.Currency
+	}
+	if query.Type != "" {
+		filter["type"] = query.Type
+	}
+	if query.After != nil {
+		filter["$or"] = bson.A{
+			bson.M{"createdAt": bson.M{"$gt": query.After.CreatedAt}},
+			bson.M{"createdAt": query.After.CreatedAt, "_id": bson.M{"$gt": query.After.ID}},
+		}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{
+			{Key: "createdAt", Value: 1},
+			{Key: "_id", Value: 1},
+		}).
+		SetBatchSize(feedBatchSize)
+
+	cursor, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	// Closing with a fresh context still kills the server-side cursor when
+	// ctx was cancelled by a client disconnect
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(ctx) {
+		var tx models.Transaction
+		if err := cursor.Decode(&tx); err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
@@ -0,0 +1,361 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"admin_statistics_api/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// QuoteUSD is the quote currency of the rates used for usdAmount
+const QuoteUSD = "USD"
+
+// Formats accepted by ParseRates
+const (
+	RateFormatCSV  = "csv"
+	RateFormatJSON = "json"
+)
+
+var (
+	ErrRateNotFound  = errors.New("no exchange rate in effect")
+	ErrReadOnlyRates = errors.New("exchange rates are read-only")
+)
+
+// RateProvider serves time-stamped exchange rates per currency pair
+type RateProvider interface {
+	// RateAt returns the rate of the pair in effect at the given instant:
+	// the latest one whose EffectiveAt is not after it
+	RateAt(ctx context.Context, base, quote string, at time.Time) (models.ExchangeRate, error)
+	// Rates lists the rates of a pair that took effect within [from, to],
+	// oldest first. Zero bounds leave that side of the range open.
+	Rates(ctx context.Context, base, quote string, from, to time.Time) ([]models.ExchangeRate, error)
+	// Series summarises every stored currency pair
+	Series(ctx context.Context) ([]models.RateSeries, error)
+	// SaveRates stores rates, replacing any of the same pair and
+	// EffectiveAt, and reports how many were new and how many replaced
+	SaveRates(ctx context.Context, rates []models.ExchangeRate) (inserted, updated int, err error)
+}
+
+// ConvertToUSD converts an amount with the USD rate of its currency in
+// effect at the given instant, rounded to the given number of places
+func ConvertToUSD(ctx context.Context, rates RateProvider, amount primitive.Decimal128, currency string, at time.Time, places int32) (primitive.Decimal128, error) {
+	value, err := models.ParseDecimal(amount.String())
+	if err != nil {
+		return primitive.Decimal128{}, fmt.Errorf("amount %s is not convertible: %v", amount, err)
+	}
+
+	rate := 1.0
+	if currency != QuoteUSD {
+		effective, err := rates.RateAt(ctx, currency, QuoteUSD, at)
+		if errors.Is(err, ErrRateNotFound) {
+			return primitive.Decimal128{}, rateNotFound(currency, QuoteUSD, at)
+		}
+		if err != nil {
+			return primitive.Decimal128{}, err
+		}
+		rate = effective.Rate
+	}
+
+	return value.MulFloat(rate).Round(places).Decimal128()
+}
+
+// rateNotFound wraps ErrRateNotFound with the pair and instant looked up
+func rateNotFound(base, quote string, at time.Time) error {
+	return fmt.Errorf("%w for %s/%s at %s", ErrRateNotFound, base, quote, at.UTC().Format(time.RFC3339))
+}
+
+// NormalizeRate upper-cases the currency codes of a rate, moves its
+// EffectiveAt to UTC and checks that the rate is a positive number
+func NormalizeRate(rate models.ExchangeRate) (models.ExchangeRate, error) {
+	rate.Base = strings.ToUpper(strings.TrimSpace(rate.Base))
+	rate.Quote = strings.ToUpper(strings.TrimSpace(rate.Quote))
+	rate.EffectiveAt = rate.EffectiveAt.UTC()
+
+	if rate.Base == "" || rate.Quote == "" {
+		return rate, errors.New("base and quote are required")
+	}
+	if rate.Base == rate.Quote {
+		return rate, fmt.Errorf("base and quote must differ, got %s/%s", rate.Base, rate.Quote)
+	}
+	if rate.EffectiveAt.IsZero() {
+		return rate, errors.New("effectiveAt is required")
+	}
+	if math.IsNaN(rate.Rate) || math.IsInf(rate.Rate, 0) || rate.Rate <= 0 {
+		return rate, fmt.Errorf("rate must be a positive number, got %v", rate.Rate)
+	}
+	return rate, nil
+}
+
+// ParseRates reads rates from a JSON array of ExchangeRate objects or from
+// CSV with a base,quote,rate,effectiveAt header (source optional). CSV
+// timestamps are RFC 3339 or dates, taken as midnight UTC. Every rate is
+// normalised; the first invalid one fails the whole input.
+func ParseRates(input io.Reader, format string) ([]models.ExchangeRate, error) {
+	var rates []models.ExchangeRate
+	switch format {
+	case RateFormatJSON:
+		if err := json.NewDecoder(input).Decode(&rates); err != nil {
+			return nil, fmt.Errorf("malformed JSON rates: %v", err)
+		}
+	case RateFormatCSV:
+		var err error
+		rates, err = parseRatesCSV(input)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported rate format %q", format)
+	}
+
+	for i := range rates {
+		normalized, err := NormalizeRate(rates[i])
+		if err != nil {
+			return nil, fmt.Errorf("rate %d: %v", i+1, err)
+		}
+		rates[i] = normalized
+	}
+	return rates, nil
+}
+
+func parseRatesCSV(input io.Reader) ([]models.ExchangeRate, error) {
+	reader := csv.NewReader(input)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{"base", "quote", "rate", "effectiveAt"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header has no %s column", required)
+		}
+	}
+
+	var rates []models.ExchangeRate
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		value := func(column string) string {
+			index, ok := columns[column]
+			if !ok || index >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[index])
+		}
+
+		rate, err := strconv.ParseFloat(value("rate"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: rate %q is not a number", line, value("rate"))
+		}
+		effectiveAt, err := parseRateTime(value("effectiveAt"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		rates = append(rates, models.ExchangeRate{
+			Base:        value("base"),
+			Quote:       value("quote"),
+			Rate:        rate,
+			EffectiveAt: effectiveAt,
+			Source:      value("source"),
+		})
+	}
+}
+
+// parseRateTime accepts RFC 3339 timestamps and dates at midnight UTC
+func parseRateTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("effectiveAt %q is not an RFC 3339 timestamp or date", value)
+}
+
+// PreloadRates copies every rate of a provider into memory, for bulk jobs
+// that would otherwise look up a rate per transaction
+func PreloadRates(ctx context.Context, rates RateProvider) (*MemoryRateProvider, error) {
+	series, err := rates.Series(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	memory := NewMemoryRateProvider()
+	for _, s := range series {
+		list, err := rates.Rates(ctx, s.Base, s.Quote, time.Time{}, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		memory.SaveRates(ctx, list)
+	}
+	return memory, nil
+}
+
+type ratePair struct {
+	base  string
+	quote string
+}
+
+// MemoryRateProvider keeps rate series in memory, sorted by EffectiveAt.
+// It mirrors MongoRateProvider and backs the file provider, the data
+// generator and bulk imports.
+type MemoryRateProvider struct {
+	mu     sync.RWMutex
+	series map[ratePair][]models.ExchangeRate
+}
+
+func NewMemoryRateProvider(rates ...models.ExchangeRate) *MemoryRateProvider {
+	provider := &MemoryRateProvider{series: make(map[ratePair][]models.ExchangeRate)}
+	provider.SaveRates(context.Background(), rates)
+	return provider
+}
+
+func (p *MemoryRateProvider) RateAt(ctx context.Context, base, quote string, at time.Time) (models.ExchangeRate, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	series := p.series[ratePair{base, quote}]
+	// Index of the first rate taking effect after at
+	next := sort.Search(len(series), func(i int) bool {
+		return series[i].EffectiveAt.After(at)
+	})
+	if next == 0 {
+		return models.ExchangeRate{}, ErrRateNotFound
+	}
+	return series[next-1], nil
+}
+
+func (p *MemoryRateProvider) Rates(ctx context.Context, base, quote string, from, to time.Time) ([]models.ExchangeRate, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	results := []models.ExchangeRate{}
+	for _, rate := range p.series[ratePair{base, quote}] {
+		if !from.IsZero() && rate.EffectiveAt.Before(from) {
+			continue
+		}
+		if !to.IsZero() && rate.EffectiveAt.After(to) {
+			break
+		}
+		results = append(results, rate)
+	}
+	return results, nil
+}
+
+func (p *MemoryRateProvider) Series(ctx context.Context) ([]models.RateSeries, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	results := []models.RateSeries{}
+	for pair, rates := range p.series {
+		if len(rates) == 0 {
+			continue
+		}
+		last := rates[len(rates)-1]
+		results = append(results, models.RateSeries{
+			Base:       pair.base,
+			Quote:      pair.quote,
+			Count:      len(rates),
+			FirstAt:    rates[0].EffectiveAt,
+			LastAt:     last.EffectiveAt,
+			LatestRate: last.Rate,
+		})
+	}
+	sortRateSeries(results)
+	return results, nil
+}
+
+func (p *MemoryRateProvider) SaveRates(ctx context.Context, rates []models.ExchangeRate) (int, int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	inserted, updated := 0, 0
+	for _, rate := range rates {
+		pair := ratePair{rate.Base, rate.Quote}
+		series := p.series[pair]
+		i := sort.Search(len(series), func(i int) bool {
+			return !series[i].EffectiveAt.Before(rate.EffectiveAt)
+		})
+		if i < len(series) && series[i].EffectiveAt.Equal(rate.EffectiveAt) {
+			series[i] = rate
+			updated++
+			continue
+		}
+		series = append(series, models.ExchangeRate{})
+		copy(series[i+1:], series[i:])
+		series[i] = rate
+		p.series[pair] = series
+		inserted++
+	}
+	return inserted, updated, nil
+}
+
+// sortRateSeries orders series by base then quote currency
+func sortRateSeries(series []models.RateSeries) {
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].Base != series[j].Base {
+			return series[i].Base < series[j].Base
+		}
+		return series[i].Quote < series[j].Quote
+	})
+}
+
+// FileRateProvider serves rates loaded once from a CSV or JSON file. It is
+// read-only; saving rates returns ErrReadOnlyRates.
+type FileRateProvider struct {
+	*MemoryRateProvider
+	path string
+}
+
+// NewFileRateProvider loads the rates of a .csv or .json file
+func NewFileRateProvider(path string) (*FileRateProvider, error) {
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if format != RateFormatCSV && format != RateFormatJSON {
+		return nil, fmt.Errorf("rate file %s must have a .csv or .json extension", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rates, err := ParseRates(file, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return &FileRateProvider{
+		MemoryRateProvider: NewMemoryRateProvider(rates...),
+		path:               path,
+	}, nil
+}
+
+func (p *FileRateProvider) SaveRates(ctx context.Context, rates []models.ExchangeRate) (int, int, error) {
+	return 0, 0, fmt.Errorf("%w: they are loaded from %s", ErrReadOnlyRates, p.path)
+}
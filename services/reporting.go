@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"admin_statistics_api/models"
+)
+
+// reportRates is the timeline of USD to reporting currency rates over a
+// range, fetched once so results can be converted without a lookup each
+type reportRates struct {
+	currency string
+	rates    []models.ExchangeRate
+	// invert is set when the stored pair is currency/USD rather than
+	// USD/currency, e.g. BTC/USD for reports in BTC
+	invert bool
+}
+
+// loadReportRates fetches the rate in effect at from and every later one
+// up to to, preferring a USD/currency series and falling back to the
+// inverse currency/USD series
+func (s *StatisticsService) loadReportRates(ctx context.Context, currency string, from, to time.Time) (*reportRates, error) {
+	if currency == QuoteUSD {
+		return &reportRates{currency: currency}, nil
+	}
+
+	pairs := []struct {
+		base, quote string
+		invert      bool
+	}{
+		{QuoteUSD, currency, false},
+		{currency, QuoteUSD, true},
+	}
+	for _, pair := range pairs {
+		var timeline []models.ExchangeRate
+		opening, err := s.rates.RateAt(ctx, pair.base, pair.quote, from)
+		if err == nil {
+			timeline = append(timeline, opening)
+		} else if !errors.Is(err, ErrRateNotFound) {
+			return nil, err
+		}
+
+		later, err := s.rates.Rates(ctx, pair.base, pair.quote, from, to)
+		if err != nil {
+			return nil, err
+		}
+		for _, rate := range later {
+			if len(timeline) == 0 || rate.EffectiveAt.After(timeline[len(timeline)-1].EffectiveAt) {
+				timeline = append(timeline, rate)
+			}
+		}
+
+		if len(timeline) > 0 {
+			return &reportRates{currency: currency, rates: timeline, invert: pair.invert}, nil
+		}
+	}
+	return nil, rateNotFound(QuoteUSD, currency, from)
+}
+
+// convert returns a USD value in the reporting currency at the rate in
+// effect at the given instant
+func (r *reportRates) convert(usd models.Decimal, at time.Time) (models.Money, error) {
+	if r.currency == QuoteUSD {
+		return models.Money{Value: usd, Currency: QuoteUSD}, nil
+	}
+
+	next := sort.Search(len(r.rates), func(i int) bool {
+		return r.rates[i].EffectiveAt.After(at)
+	})
+	if next == 0 {
+		return models.Money{}, rateNotFound(QuoteUSD, r.currency, at)
+	}
+
+	rate := r.rates[next-1].Rate
+	if r.invert {
+		return models.Money{Value: usd.DivFloat(rate), Currency: r.currency}, nil
+	}
+	return models.Money{Value: usd.MulFloat(rate), Currency: r.currency}, nil
+}
+
+// ReportGrossGamingRevenue fills in Value in the reporting currency, at the
+// rate in effect at the end of the range
+func (s *StatisticsService) ReportGrossGamingRevenue(ctx context.Context, results []models.GrossGamingRevenue, currency string, from, to time.Time) error {
+	rates, err := s.loadReportRates(ctx, currency, from, to)
+	if err != nil {
+		return err
+	}
+	for i := range results {
+		if results[i].Value, err = rates.convert(results[i].USDValue, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReportWagerVolume fills in Value in the reporting currency. Each bucket
+// is converted at the rate in effect at its end, or at the end of the
+// range for the last, partial bucket.
+func (s *StatisticsService) ReportWagerVolume(ctx context.Context, results []models.WagerVolume, currency string, from, to time.Time, granularity string, loc *time.Location) error {
+	rates, err := s.loadReportRates(ctx, currency, from, to)
+	if err != nil {
+		return err
+	}
+	for i := range results {
+		at := closingInstant(results[i].Bucket, granularity, to, loc)
+		if results[i].Value, err = rates.convert(results[i].USDValue, at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReportDailyWagerVolume is ReportWagerVolume for the daily volume shape
+func (s *StatisticsService) ReportDailyWagerVolume(ctx context.Context, results []models.DailyWagerVolume, currency string, from, to time.Time, loc *time.Location) error {
+	rates, err := s.loadReportRates(ctx, currency, from, to)
+	if err != nil {
+		return err
+	}
+	for i := range results {
+		day, err := time.ParseInLocation("2006-01-02", results[i].Date, loc)
+		if err != nil {
+			return err
+		}
+		at := closingInstant(day, GranularityDay, to, loc)
+		if results[i].Value, err = rates.convert(results[i].USDValue, at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReportUserWagerPercentile fills in Value in the reporting currency, at
+// the rate in effect at the end of the range
+func (s *StatisticsService) ReportUserWagerPercentile(ctx context.Context, result *models.UserWagerPercentile, currency string, from, to time.Time) error {
+	rates, err := s.loadReportRates(ctx, currency, from, to)
+	if err != nil {
+		return err
+	}
+	result.Value, err = rates.convert(result.TotalWagered, to)
+	return err
+}
+
+// ReportUserSummary fills in the summary's values in the reporting
+// currency, at the rate in effect at the end of the range
+func (s *StatisticsService) ReportUserSummary(ctx context.Context, result *models.UserSummary, currency string, from, to time.Time) error {
+	rates, err := s.loadReportRates(ctx, currency, from, to)
+	if err != nil {
+		return err
+	}
+	// The first failed conversion is kept and skips the rest
+	convert := func(usd models.Decimal, value *models.Money) {
+		if err == nil {
+			*value, err = rates.convert(usd, to)
+		}
+	}
+	convert(result.TotalWageredUSD, &result.TotalWageredValue)
+	convert(result.TotalPaidOutUSD, &result.TotalPaidOutValue)
+	convert(result.NetGGRUSD, &result.NetGGRValue)
+	for i := range result.ByCurrency {
+		summary := &result.ByCurrency[i]
+		convert(summary.WageredUSD, &summary.WageredValue)
+		convert(summary.PaidOutUSD, &summary.PaidOutValue)
+		convert(summary.NetGGRUSD, &summary.NetGGRValue)
+		convert(summary.LargestWinUSD, &summary.LargestWinValue)
+	}
+	return err
+}
+
+// ReportLeaderboard fills in each entry's totals in the reporting
+// currency, at the rate in effect at the end of the range
+func (s *StatisticsService) ReportLeaderboard(ctx context.Context, result *models.Leaderboard, currency string, from, to time.Time) error {
+	rates, err := s.loadReportRates(ctx, currency, from, to)
+	if err != nil {
+		return err
+	}
+	convert := func(usd models.Decimal, value *models.Money) {
+		if err == nil {
+			*value, err = rates.convert(usd, to)
+		}
+	}
+	for i := range result.Entries {
+		e := &result.Entries[i]
+		convert(e.WageredUSD, &e.WageredValue)
+		convert(e.PaidOutUSD, &e.PaidOutValue)
+		convert(e.NetLossUSD, &e.NetLossValue)
+	}
+	return err
+}
+
+// ReportComparison fills in the deltas in the reporting currency. Each
+// window's USD totals are converted at the rate in effect at its end, so
+// the change includes the move of the rate between the windows.
+func (s *StatisticsService) ReportComparison(ctx context.Context, comparison *models.PeriodComparison, currency string, to, prevTo time.Time) error {
+	current, err := s.loadReportRates(ctx, currency, to, to)
+	if err != nil {
+		return err
+	}
+	previous, err := s.loadReportRates(ctx, currency, prevTo, prevTo)
+	if err != nil {
+		return err
+	}
+
+	report := func(delta models.ValueDelta) (models.ValueDelta, error) {
+		cur, err := current.convert(delta.Current, to)
+		if err != nil {
+			return models.ValueDelta{}, err
+		}
+		prev, err := previous.convert(delta.Previous, prevTo)
+		if err != nil {
+			return models.ValueDelta{}, err
+		}
+		return valueDelta(cur.Value, prev.Value), nil
+	}
+
+	for i := range comparison.ByCurrency {
+		if comparison.ByCurrency[i].Value, err = report(comparison.ByCurrency[i].USDValue); err != nil {
+			return err
+		}
+	}
+	if comparison.Total, err = report(comparison.TotalUSD); err != nil {
+		return err
+	}
+	comparison.ReportCurrency = currency
+	return nil
+}
+
+// closingInstant is the last instant of the bucket starting at start,
+// capped at the end of the range
+func closingInstant(start time.Time, granularity string, to time.Time, loc *time.Location) time.Time {
+	end := nextBucket(start, granularity, loc).Add(-time.Nanosecond)
+	if end.After(to) {
+		return to
+	}
+	return end
+}
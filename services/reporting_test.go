@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"admin_statistics_api/models"
+)
+
+// eurRates quotes USD/EUR at 0.9 from March and at 0.8 from April 2024
+func eurRates() *MemoryRateProvider {
+	return NewMemoryRateProvider(
+		models.ExchangeRate{Base: QuoteUSD, Quote: "EUR", Rate: 0.9, EffectiveAt: date("2024-03-01T00:00:00Z")},
+		models.ExchangeRate{Base: QuoteUSD, Quote: "EUR", Rate: 0.8, EffectiveAt: date("2024-04-01T00:00:00Z")},
+	)
+}
+
+func TestReportUserSummary(t *testing.T) {
+	service := NewStatisticsService(NewMemoryTransactionStore(), nil, eurRates())
+	summary := &models.UserSummary{
+		TotalWageredUSD: decimal("100"),
+		NetGGRUSD:       decimal("-10"),
+		ByCurrency:      []models.UserCurrencySummary{{Currency: "BTC", WageredUSD: decimal("100"), LargestWinUSD: decimal("110")}},
+	}
+
+	// Converted at the rate in effect at the end of the range
+	err := service.ReportUserSummary(context.Background(), summary, "EUR", date("2024-03-01T00:00:00Z"), date("2024-04-15T00:00:00Z"))
+	if err != nil {
+		t.Fatalf("ReportUserSummary: %v", err)
+	}
+	if summary.TotalWageredValue.Currency != "EUR" || summary.TotalWageredValue.Value.Cmp(decimal("80")) != 0 ||
+		summary.NetGGRValue.Value.Cmp(decimal("-8")) != 0 || summary.ByCurrency[0].LargestWinValue.Value.Cmp(decimal("88")) != 0 {
+		t.Errorf("got %+v, want values at 0.8", summary)
+	}
+
+	err = service.ReportUserSummary(context.Background(), summary, "GBP", date("2024-03-01T00:00:00Z"), date("2024-04-15T00:00:00Z"))
+	if !errors.Is(err, ErrRateNotFound) {
+		t.Errorf("got %v for a currency without rates, want ErrRateNotFound", err)
+	}
+}
+
+func TestReportComparison(t *testing.T) {
+	service := NewStatisticsService(NewMemoryTransactionStore(), nil, eurRates())
+	comparison := comparePeriods(
+		map[string]currencyTotal{"BTC": {amount: decimal("1"), usdValue: decimal("100")}},
+		map[string]currencyTotal{"BTC": {amount: decimal("1"), usdValue: decimal("100")}},
+	)
+
+	// The same USD total is worth less EUR at the end of the current window
+	err := service.ReportComparison(context.Background(), comparison, "EUR", date("2024-04-30T00:00:00Z"), date("2024-03-31T00:00:00Z"))
+	if err != nil {
+		t.Fatalf("ReportComparison: %v", err)
+	}
+	total := comparison.Total
+	if comparison.ReportCurrency != "EUR" || total.Current.Cmp(decimal("80")) != 0 || total.Previous.Cmp(decimal("90")) != 0 ||
+		total.Change.Cmp(decimal("-10")) != 0 || comparison.ByCurrency[0].Value.Change.Cmp(decimal("-10")) != 0 {
+		t.Errorf("got %+v, want 80 against 90 EUR", comparison)
+	}
+	if !comparison.TotalUSD.Change.IsZero() {
+		t.Errorf("got USD change %s, want 0", comparison.TotalUSD.Change)
+	}
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"admin_statistics_api/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// truncateToDay returns midnight UTC of the day containing t
+func truncateToDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// dailyStatDelta is what a batch of new transactions adds to one rollup.
+// UserCount is left at zero because only the store knows which users the
+// day has already seen.
+type dailyStatDelta struct {
+	models.DailyStat
+	users []primitive.ObjectID
+}
+
+// dailyStatDeltas groups transactions by UTC day, currency and type,
+// sorted by that key
+func dailyStatDeltas(txs []models.Transaction) []dailyStatDelta {
+	type bucket struct {
+		day      time.Time
+		currency string
+		txType   string
+	}
+
+	byBucket := make(map[bucket]*dailyStatDelta)
+	seen := make(map[bucket]map[primitive.ObjectID]bool)
+	for _, tx := range txs {
+		key := bucket{
+			day:      truncateToDay(tx.CreatedAt),
+			currency: tx.Currency,
+			txType:   tx.Type,
+		}
+		delta, ok := byBucket[key]
+		if !ok {
+			delta = &dailyStatDelta{DailyStat: models.DailyStat{Day: key.day, Currency: key.currency, Type: key.txType}}
+			byBucket[key] = delta
+			seen[key] = make(map[primitive.ObjectID]bool)
+		}
+		delta.Count++
+		delta.Amount = delta.Amount.Add(models.DecimalFrom128(tx.Amount))
+		delta.USDAmount = delta.USDAmount.Add(models.DecimalFrom128(tx.USDAmount))
+		if !seen[key][tx.UserID] {
+			seen[key][tx.UserID] = true
+			delta.users = append(delta.users, tx.UserID)
+		}
+	}
+
+	deltas := make([]dailyStatDelta, 0, len(byBucket))
+	for _, delta := range byBucket {
+		deltas = append(deltas, *delta)
+	}
+	sort.Slice(deltas, func(i, j int) bool {
+		if !deltas[i].Day.Equal(deltas[j].Day) {
+			return deltas[i].Day.Before(deltas[j].Day)
+		}
+		if deltas[i].Currency != deltas[j].Currency {
+			return deltas[i].Currency < deltas[j].Currency
+		}
+		return deltas[i].Type < deltas[j].Type
+	})
+	return deltas
+}
+
+// dayRange describes how an inclusive [from, to] range is answered: the
+// whole UTC days in [wholeFrom, wholeTo) come from the daily_stats rollups
+// and any partial days at either edge come from raw transactions
+type dayRange struct {
+	wholeFrom time.Time
+	wholeTo   time.Time
+	// edges are inclusive [from, to] ranges of partial days
+	edges [][2]time.Time
+}
+
+// splitWholeDays splits the inclusive range [from, to] into whole UTC days
+// and partial edge days
+func splitWholeDays(from, to time.Time) dayRange {
+	wholeFrom := truncateToDay(from)
+	if wholeFrom.Before(from) {
+		wholeFrom = wholeFrom.AddDate(0, 0, 1)
+	}
+	// A day is whole only if its last instant is covered by the range
+	wholeTo := truncateToDay(to.Add(time.Nanosecond))
+
+	if !wholeFrom.Before(wholeTo) {
+		return dayRange{edges: [][2]time.Time{{from, to}}}
+	}
+
+	r := dayRange{wholeFrom: wholeFrom, wholeTo: wholeTo}
+	if from.Before(wholeFrom) {
+		r.edges = append(r.edges, [2]time.Time{from, wholeFrom.Add(-time.Nanosecond)})
+	}
+	if !to.Before(wholeTo) {
+		r.edges = append(r.edges, [2]time.Time{wholeTo, to})
+	}
+	return r
+}
+
+// hasWholeDays reports whether any part of the range can use rollups
+func (r dayRange) hasWholeDays() bool {
+	return r.wholeFrom.Before(r.wholeTo)
+}
+
+// ggrFromDailyStats folds rollups into GGR per currency
+func ggrFromDailyStats(stats []models.DailyStat) []models.GrossGamingRevenue {
+	var results []models.GrossGamingRevenue
+	for _, stat := range stats {
+		switch stat.Type {
+		case "Wager":
+			results = append(results, models.GrossGamingRevenue{
+				Currency: stat.Currency,
+				Amount:   stat.Amount,
+				USDValue: stat.USDAmount,
+			})
+		case "Payout":
+			results = append(results, models.GrossGamingRevenue{
+				Currency: stat.Currency,
+				Amount:   stat.Amount.Neg(),
+				USDValue: stat.USDAmount.Neg(),
+			})
+		}
+	}
+	return mergeGrossGamingRevenue(results)
+}
+
+// mergeGrossGamingRevenue sums GGR entries that share a currency
+func mergeGrossGamingRevenue(parts ...[]models.GrossGamingRevenue) []models.GrossGamingRevenue {
+	byCurrency := make(map[string]*models.GrossGamingRevenue)
+	for _, part := range parts {
+		for _, ggr := range part {
+			merged, ok := byCurrency[ggr.Currency]
+			if !ok {
+				merged = &models.GrossGamingRevenue{Currency: ggr.Currency}
+				byCurrency[ggr.Currency] = merged
+			}
+			merged.Amount = merged.Amount.Add(ggr.Amount)
+			merged.USDValue = merged.USDValue.Add(ggr.USDValue)
+		}
+	}
+
+	var results []models.GrossGamingRevenue
+	for _, ggr := range byCurrency {
+		results = append(results, *ggr)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Currency < results[j].Currency
+	})
+	return results
+}
+
+// volumeFromDailyStats folds wager rollups into UTC buckets of the given
+// granularity, which must be a day or coarser
+func volumeFromDailyStats(stats []models.DailyStat, granularity string) []models.WagerVolume {
+	var results []models.WagerVolume
+	for _, stat := range stats {
+		if stat.Type != "Wager" {
+			continue
+		}
+		results = append(results, models.WagerVolume{
+			Bucket:   truncateToBucket(stat.Day, granularity, time.UTC),
+			Currency: stat.Currency,
+			Amount:   stat.Amount,
+			USDValue: stat.USDAmount,
+		})
+	}
+	return mergeWagerVolume(results)
+}
+
+// mergeWagerVolume sums volume entries that share a bucket and currency
+// and sorts them by bucket then currency
+func mergeWagerVolume(parts ...[]models.WagerVolume) []models.WagerVolume {
+	type key struct {
+		bucket   time.Time
+		currency string
+	}
+
+	byBucket := make(map[key]*models.WagerVolume)
+	for _, part := range parts {
+		for _, volume := range part {
+			k := key{bucket: volume.Bucket.UTC(), currency: volume.Currency}
+			merged, ok := byBucket[k]
+			if !ok {
+				merged = &models.WagerVolume{Bucket: k.bucket, Currency: volume.Currency}
+				byBucket[k] = merged
+			}
+			merged.Amount = merged.Amount.Add(volume.Amount)
+			merged.USDValue = merged.USDValue.Add(volume.USDValue)
+		}
+	}
+
+	var results []models.WagerVolume
+	for _, volume := range byBucket {
+		results = append(results, *volume)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if !results[i].Bucket.Equal(results[j].Bucket) {
+			return results[i].Bucket.Before(results[j].Bucket)
+		}
+		return results[i].Currency < results[j].Currency
+	})
+	return results
+}
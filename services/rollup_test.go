@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"admin_statistics_api/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSplitWholeDays(t *testing.T) {
+	tests := []struct {
+		name               string
+		from, to           string
+		wholeFrom, wholeTo string
+		edges              [][2]string
+	}{
+		{
+			name:      "whole days only",
+			from:      "2024-03-01T00:00:00Z",
+			to:        "2024-03-03T23:59:59.999999999Z",
+			wholeFrom: "2024-03-01T00:00:00Z",
+			wholeTo:   "2024-03-04T00:00:00Z",
+		},
+		{
+			name:      "partial days at both edges",
+			from:      "2024-03-01T10:00:00Z",
+			to:        "2024-03-03T12:00:00Z",
+			wholeFrom: "2024-03-02T00:00:00Z",
+			wholeTo:   "2024-03-03T00:00:00Z",
+			edges: [][2]string{
+				{"2024-03-01T10:00:00Z", "2024-03-01T23:59:59.999999999Z"},
+				{"2024-03-03T00:00:00Z", "2024-03-03T12:00:00Z"},
+			},
+		},
+		{
+			name:  "within a single day",
+			from:  "2024-03-01T10:00:00Z",
+			to:    "2024-03-01T12:00:00Z",
+			edges: [][2]string{{"2024-03-01T10:00:00Z", "2024-03-01T12:00:00Z"}},
+		},
+		{
+			// A day ending a millisecond early is not whole
+			name:  "one day short of its last instant",
+			from:  "2024-03-01T00:00:00Z",
+			to:    "2024-03-01T23:59:59.998Z",
+			edges: [][2]string{{"2024-03-01T00:00:00Z", "2024-03-01T23:59:59.998Z"}},
+		},
+		{
+			name:      "offset bounds are taken in UTC",
+			from:      "2024-03-01T00:00:00+01:00",
+			to:        "2024-03-02T23:59:59.999999999Z",
+			wholeFrom: "2024-03-01T00:00:00Z",
+			wholeTo:   "2024-03-03T00:00:00Z",
+			edges: [][2]string{
+				{"2024-03-01T00:00:00+01:00", "2024-02-29T23:59:59.999999999Z"},
+			},
+		},
+	}
+
+	parse := func(value string) time.Time {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := splitWholeDays(parse(tt.from), parse(tt.to))
+
+			if tt.wholeFrom == "" {
+				if r.hasWholeDays() {
+					t.Errorf("got whole days [%v, %v), want none", r.wholeFrom, r.wholeTo)
+				}
+			} else if !r.wholeFrom.Equal(parse(tt.wholeFrom)) || !r.wholeTo.Equal(parse(tt.wholeTo)) {
+				t.Errorf("got whole days [%v, %v), want [%s, %s)", r.wholeFrom, r.wholeTo, tt.wholeFrom, tt.wholeTo)
+			}
+
+			if len(r.edges) != len(tt.edges) {
+				t.Fatalf("got %d edges %v, want %d", len(r.edges), r.edges, len(tt.edges))
+			}
+			for i, edge := range tt.edges {
+				if !r.edges[i][0].Equal(parse(edge[0])) || !r.edges[i][1].Equal(parse(edge[1])) {
+					t.Errorf("edge %d: got [%v, %v], want [%s, %s]", i, r.edges[i][0], r.edges[i][1], edge[0], edge[1])
+				}
+			}
+		})
+	}
+}
+
+func TestRollupsMatchRawTransactions(t *testing.T) {
+	store := NewMemoryTransactionStore(
+		transaction(alice, "r1", "Wager", "0.12345678", "BTC", "5000.01", date("2024-03-01T00:00:00Z")),
+		transaction(alice, "r1", "Payout", "0.2", "BTC", "8000", date("2024-03-01T05:00:00Z")),
+		transaction(bob, "r2", "Wager", "10.01", "USD", "10.01", date("2024-03-01T23:59:59Z")),
+		transaction(bob, "r3", "Wager", "20.02", "USD", "20.02", date("2024-03-02T00:00:00Z")),
+		transaction(carol, "r4", "Payout", "5", "USD", "5", date("2024-03-02T13:00:00Z")),
+	)
+	ctx := context.Background()
+	from, to := date("2024-03-01T00:00:00Z"), date("2024-03-03T00:00:00Z")
+
+	stats, err := store.DailyStats(ctx, from, to)
+	if err != nil {
+		t.Fatalf("DailyStats: %v", err)
+	}
+	raw, err := store.GrossGamingRevenue(ctx, from, to.Add(-time.Nanosecond))
+	if err != nil {
+		t.Fatalf("GrossGamingRevenue: %v", err)
+	}
+
+	rolled := ggrFromDailyStats(stats)
+	if len(rolled) != len(raw) {
+		t.Fatalf("got %d currencies from rollups, want %d", len(rolled), len(raw))
+	}
+	for i := range raw {
+		if rolled[i].Currency != raw[i].Currency ||
+			rolled[i].Amount.Cmp(raw[i].Amount) != 0 ||
+			rolled[i].USDValue.Cmp(raw[i].USDValue) != 0 {
+			t.Errorf("got %+v from rollups, want %+v", rolled[i], raw[i])
+		}
+	}
+
+	volumes := volumeFromDailyStats(stats, GranularityDay)
+	want := []struct {
+		day, currency, amount string
+	}{
+		{"2024-03-01", "BTC", "0.12345678"},
+		{"2024-03-01", "USD", "10.01"},
+		{"2024-03-02", "USD", "20.02"},
+	}
+	if len(volumes) != len(want) {
+		t.Fatalf("got %d volumes, want %d: %+v", len(volumes), len(want), volumes)
+	}
+	for i, w := range want {
+		if volumes[i].Bucket.Format("2006-01-02") != w.day || volumes[i].Currency != w.currency || volumes[i].Amount.Cmp(decimal(w.amount)) != 0 {
+			t.Errorf("volume %d: got %+v, want %+v", i, volumes[i], w)
+		}
+	}
+}
+
+func TestMergeGrossGamingRevenue(t *testing.T) {
+	merged := mergeGrossGamingRevenue(
+		[]models.GrossGamingRevenue{
+			{Currency: "USD", Amount: decimal("0.1"), USDValue: decimal("0.1")},
+			{Currency: "BTC", Amount: decimal("1"), USDValue: decimal("40000")},
+		},
+		[]models.GrossGamingRevenue{
+			{Currency: "USD", Amount: decimal("0.2"), USDValue: decimal("0.2")},
+		},
+		nil,
+	)
+
+	if len(merged) != 2 || merged[0].Currency != "BTC" || merged[1].Currency != "USD" {
+		t.Fatalf("got %+v, want BTC then USD", merged)
+	}
+	// Decimal sums are exact where float64 would give 0.30000000000000004
+	if merged[1].Amount.Cmp(decimal("0.3")) != 0 {
+		t.Errorf("got USD %s, want 0.3", merged[1].Amount)
+	}
+}
+
+func TestAddToDailyStatsMatchesRebuild(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTransactionStore(
+		transaction(alice, "r1", "Wager", "1.5", "USD", "1.5", date("2024-03-01T10:00:00Z")),
+		transaction(bob, "r2", "Payout", "4", "USD", "4", date("2024-03-01T11:00:00Z")),
+	)
+
+	// Later batches hit existing rollups, new ones and users the rollups
+	// have already counted
+	batches := [][]models.Transaction{
+		{
+			transaction(alice, "r3", "Wager", "2.25", "USD", "2.25", date("2024-03-01T12:00:00Z")),
+			transaction(bob, "r4", "Wager", "3", "USD", "3", date("2024-03-01T13:00:00Z")),
+		},
+		{
+			transaction(alice, "r5", "Wager", "0.01", "BTC", "400", date("2024-03-02T00:00:00Z")),
+			transaction(carol, "r6", "Wager", "5", "USD", "5", date("2024-03-01T23:59:59Z")),
+			transaction(carol, "r7", "Wager", "5", "USD", "5", date("2024-03-01T23:59:59.5Z")),
+		},
+	}
+	for _, batch := range batches {
+		// The feed delivers rows as stored, IDs included
+		for i := range batch {
+			batch[i].ID = primitive.NewObjectID()
+		}
+		store.Add(batch...)
+		if err := store.AddToDailyStats(ctx, batch); err != nil {
+			t.Fatalf("AddToDailyStats: %v", err)
+		}
+	}
+
+	from, to := date("2024-03-01T00:00:00Z"), date("2024-03-03T00:00:00Z")
+	incremental, _ := store.DailyStats(ctx, from, to)
+	if err := store.RebuildDailyStats(ctx, from, to); err != nil {
+		t.Fatalf("RebuildDailyStats: %v", err)
+	}
+	rebuilt, _ := store.DailyStats(ctx, from, to)
+
+	if len(incremental) != len(rebuilt) {
+		t.Fatalf("got %d rollups incrementally, want %d: %+v", len(incremental), len(rebuilt), incremental)
+	}
+	for i := range rebuilt {
+		got, want := incremental[i], rebuilt[i]
+		if !got.Day.Equal(want.Day) || got.Currency != want.Currency || got.Type != want.Type ||
+			got.Count != want.Count || got.UserCount != want.UserCount ||
+			got.Amount.Cmp(want.Amount) != 0 || got.USDAmount.Cmp(want.USDAmount) != 0 {
+			t.Errorf("rollup %d: got %+v incrementally, want %+v", i, got, want)
+		}
+	}
+
+	// alice, bob and carol wagered USD on the 1st, carol twice
+	if wager := rebuilt[1]; wager.Type != "Wager" || wager.Count != 5 || wager.UserCount != 3 || wager.Amount.Cmp(decimal("16.75")) != 0 {
+		t.Errorf("got USD wagers %+v, want 5 wagers of 16.75 by 3 users", wager)
+	}
+}
+
+func TestRebuildDailyStatsDropsStaleRollups(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTransactionStore(
+		transaction(alice, "r1", "Wager", "1", "USD", "1", date("2024-03-01T10:00:00Z")),
+		transaction(alice, "r1", "Payout", "2", "USD", "2", date("2024-03-01T10:01:00Z")),
+		transaction(bob, "r2", "Wager", "1", "EUR", "1.1", date("2024-03-02T10:00:00Z")),
+	)
+	day := func(value string) (time.Time, time.Time) {
+		from := date(value)
+		return from, from.AddDate(0, 0, 1)
+	}
+
+	// The payout is deleted behind the store's back, as a change stream
+	// delete would be
+	store.transactions = append(store.transactions[:1:1], store.transactions[2])
+
+	from, to := day("2024-03-01T00:00:00Z")
+	if err := store.RebuildDailyStats(ctx, from, to); err != nil {
+		t.Fatalf("RebuildDailyStats: %v", err)
+	}
+
+	stats, _ := store.DailyStats(ctx, from, date("2024-03-03T00:00:00Z"))
+	if len(stats) != 2 || stats[0].Type != "Wager" || stats[1].Currency != "EUR" {
+		t.Fatalf("got %+v, want the USD wager and the untouched EUR rollup", stats)
+	}
+}
+
+func TestAddToDailyStatsSkipsCountedTransactions(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTransactionStore()
+	from, to := date("2024-03-01T00:00:00Z"), date("2024-03-02T00:00:00Z")
+
+	// The rebuild of a dirty day reads a row the feed has not delivered yet
+	store.Add(
+		transaction(alice, "r1", "Wager", "1", "USD", "1", date("2024-03-01T10:00:00Z")),
+		transaction(bob, "r2", "Wager", "2", "USD", "2", date("2024-03-01T11:00:00Z")),
+	)
+	wager, late := store.transactions[0], store.transactions[1]
+	if err := store.RebuildDailyStats(ctx, from, to); err != nil {
+		t.Fatalf("RebuildDailyStats: %v", err)
+	}
+
+	// Its later delivery, a repeat within one batch and a redelivered batch
+	// are all counted once
+	for _, batch := range [][]models.Transaction{{late, late}, {wager, late}} {
+		if err := store.AddToDailyStats(ctx, batch); err != nil {
+			t.Fatalf("AddToDailyStats: %v", err)
+		}
+	}
+
+	stats, _ := store.DailyStats(ctx, from, to)
+	if len(stats) != 1 || stats[0].Count != 2 || stats[0].UserCount != 2 || stats[0].Amount.Cmp(models.DecimalFrom128(decimal128("3"))) != 0 {
+		t.Fatalf("got %+v, want one rollup of 2 wagers totalling 3 by 2 users", stats)
+	}
+
+	// A rebuild forgets transactions that are gone, so one inserted again
+	// under the same ID is counted again
+	store.transactions = store.transactions[:1]
+	if err := store.RebuildDailyStats(ctx, from, to); err != nil {
+		t.Fatalf("RebuildDailyStats: %v", err)
+	}
+	store.Add(late)
+	if err := store.AddToDailyStats(ctx, []models.Transaction{late}); err != nil {
+		t.Fatalf("AddToDailyStats: %v", err)
+	}
+	if stats, _ := store.DailyStats(ctx, from, to); len(stats) != 1 || stats[0].Count != 2 {
+		t.Fatalf("got %+v, want the re-inserted wager counted", stats)
+	}
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"admin_statistics_api/models"
+)
+
+// TransactionChanges is a batch of changes read from a TransactionFeed
+type TransactionChanges struct {
+	// Inserted are new transactions, added to the rollups incrementally
+	Inserted []models.Transaction
+	// Rebuild holds the creation times of transactions that were updated,
+	// replaced or deleted. Their days are rebuilt from raw transactions.
+	Rebuild []time.Time
+}
+
+// TransactionFeed reports transactions that were inserted or changed. Run
+// blocks until ctx is cancelled or the feed fails, passing each batch to
+// handle; a feed only advances its persisted position once handle has
+// returned without error. A feed that has no persisted position yet takes
+// its current position, calls bootstrap and only saves that position once
+// bootstrap has returned without error, so changes made while it runs are
+// still delivered.
+type TransactionFeed interface {
+	Run(ctx context.Context, bootstrap func(ctx context.Context) error, handle func(ctx context.Context, changes TransactionChanges) error) error
+}
+
+// RollupWorker keeps daily_stats current as transactions arrive and drops
+// cached statistics that cover the affected days
+type RollupWorker struct {
+	store      TransactionStore
+	cache      Cache
+	feed       TransactionFeed
+	retryDelay time.Duration
+
+	// dirty holds days whose rollups may have been partly updated by a
+	// failed batch; they are rebuilt when the batch is delivered again
+	dirty map[time.Time]bool
+}
+
+func NewRollupWorker(store TransactionStore, cache Cache, feed TransactionFeed) *RollupWorker {
+	return &RollupWorker{
+		store:      store,
+		cache:      cache,
+		feed:       feed,
+		retryDelay: 5 * time.Second,
+		dirty:      make(map[time.Time]bool),
+	}
+}
+
+// Run consumes the feed until ctx is cancelled, restarting it after failures
+func (w *RollupWorker) Run(ctx context.Context) {
+	for {
+		err := w.feed.Run(ctx, w.bootstrap, w.apply)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Rollup worker feed stopped: %v, retrying in %v", err, w.retryDelay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.retryDelay):
+		}
+	}
+}
+
+// bootstrap rebuilds the rollups of every day when the feed starts without
+// a saved position, so that transactions stored before the worker first
+// ran are counted as well
+func (w *RollupWorker) bootstrap(ctx context.Context) error {
+	log.Println("Rollup feed has no saved position, rebuilding the rollups of every day")
+	startTime := time.Now()
+
+	from := time.Unix(0, 0).UTC()
+	to := truncateToDay(time.Now().Add(maxCreatedAtSkew)).AddDate(0, 0, 1)
+	if err := w.store.RebuildDailyStats(ctx, from, to); err != nil {
+		return fmt.Errorf("failed to bootstrap rollups: %v", err)
+	}
+
+	log.Printf("Rollups rebuilt in %v", time.Since(startTime))
+	w.invalidateAll(ctx)
+	return nil
+}
+
+// apply adds inserted transactions to the rollups of their days and
+// rebuilds the days that changed in place or were left dirty by a failed
+// batch, then invalidates the cached statistics overlapping those days.
+// A rebuilt day already includes the batch's inserts into it, so they are
+// not added again; inserts it covered that are delivered in a later batch
+// are skipped by AddToDailyStats.
+func (w *RollupWorker) apply(ctx context.Context, changes TransactionChanges) error {
+	for _, t := range changes.Rebuild {
+		w.dirty[truncateToDay(t)] = true
+	}
+
+	rebuild := sortedDays(w.dirty)
+	for _, day := range rebuild {
+		if err := w.store.RebuildDailyStats(ctx, day, day.AddDate(0, 0, 1)); err != nil {
+			return err
+		}
+	}
+
+	touched := make(map[time.Time]bool)
+	var inserted []models.Transaction
+	for _, tx := range changes.Inserted {
+		day := truncateToDay(tx.CreatedAt)
+		touched[day] = true
+		if !w.dirty[day] {
+			inserted = append(inserted, tx)
+		}
+	}
+
+	if err := w.store.AddToDailyStats(ctx, inserted); err != nil {
+		// Some increments may have been written and the batch will be
+		// delivered again, so its days are rebuilt then instead
+		for _, tx := range inserted {
+			w.dirty[truncateToDay(tx.CreatedAt)] = true
+		}
+		return err
+	}
+
+	for _, day := range rebuild {
+		touched[day] = true
+		delete(w.dirty, day)
+	}
+	w.invalidate(ctx, sortedDays(touched))
+	return nil
+}
+
+func sortedDays(dayset map[time.Time]bool) []time.Time {
+	days := make([]time.Time, 0, len(dayset))
+	for day := range dayset {
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+// invalidateAll deletes every cached statistic
+func (w *RollupWorker) invalidateAll(ctx context.Context) {
+	if w.cache == nil {
+		return
+	}
+
+	for _, prefix := range statsCachePrefixes {
+		keys, err := w.cache.Scan(ctx, prefix+":*")
+		if err != nil || len(keys) == 0 {
+			continue
+		}
+		if err := w.cache.Delete(ctx, keys...); err != nil {
+			log.Printf("Failed to invalidate cached statistics: %v", err)
+		}
+	}
+}
+
+// invalidate deletes cache entries whose range overlaps any of the days
+func (w *RollupWorker) invalidate(ctx context.Context, days []time.Time) {
+	if w.cache == nil || len(days) == 0 {
+		return
+	}
+
+	for _, prefix := range statsCachePrefixes {
+		keys, err := w.cache.Scan(ctx, prefix+":*")
+		if err != nil {
+			return
+		}
+
+		var stale []string
+		for _, key := range keys {
+			from, to, ok := cacheKeyRange(key)
+			if !ok {
+				continue
+			}
+			for _, day := range days {
+				if !day.After(to) && !day.AddDate(0, 0, 1).Before(from) {
+					stale = append(stale, key)
+					break
+				}
+			}
+		}
+
+		if len(stale) > 0 {
+			if err := w.cache.Delete(ctx, stale...); err != nil {
+				log.Printf("Failed to invalidate cached statistics: %v", err)
+			}
+		}
+	}
+}
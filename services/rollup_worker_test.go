@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"admin_statistics_api/models"
+)
+
+func TestDailyStatDeltas(t *testing.T) {
+	deltas := dailyStatDeltas([]models.Transaction{
+		transaction(alice, "r1", "Wager", "1.5", "USD", "1.5", date("2024-03-02T10:00:00Z")),
+		transaction(alice, "r2", "Wager", "2.5", "USD", "2.5", date("2024-03-02T11:00:00Z")),
+		transaction(bob, "r3", "Wager", "1", "USD", "1", date("2024-03-02T12:00:00Z")),
+		transaction(bob, "r3", "Payout", "3", "USD", "3", date("2024-03-02T12:01:00Z")),
+		transaction(carol, "r4", "Wager", "0.1", "BTC", "4000", date("2024-03-01T23:00:00Z")),
+	})
+
+	want := []struct {
+		day, currency, txType string
+		count                 int64
+		amount                string
+		users                 int
+	}{
+		{"2024-03-01", "BTC", "Wager", 1, "0.1", 1},
+		{"2024-03-02", "USD", "Payout", 1, "3", 1},
+		{"2024-03-02", "USD", "Wager", 3, "5", 2},
+	}
+	if len(deltas) != len(want) {
+		t.Fatalf("got %d deltas, want %d: %+v", len(deltas), len(want), deltas)
+	}
+	for i, w := range want {
+		got := deltas[i]
+		if got.Day.Format("2006-01-02") != w.day || got.Currency != w.currency || got.Type != w.txType ||
+			got.Count != w.count || got.Amount.Cmp(decimal(w.amount)) != 0 || len(got.users) != w.users {
+			t.Errorf("delta %d: got %s %s %s count %d amount %s users %d, want %+v",
+				i, got.Day.Format("2006-01-02"), got.Currency, got.Type, got.Count, got.Amount, len(got.users), w)
+		}
+	}
+}
+
+// recordingStore records how the rollup worker maintains the rollups
+type recordingStore struct {
+	*MemoryTransactionStore
+	added   []models.Transaction
+	rebuilt []time.Time
+	failAdd bool
+}
+
+func (s *recordingStore) AddToDailyStats(ctx context.Context, txs []models.Transaction) error {
+	if s.failAdd {
+		return errors.New("write failed")
+	}
+	s.added = append(s.added, txs...)
+	return nil
+}
+
+func (s *recordingStore) RebuildDailyStats(ctx context.Context, from, to time.Time) error {
+	s.rebuilt = append(s.rebuilt, from)
+	return nil
+}
+
+func TestRollupWorkerApply(t *testing.T) {
+	store := &recordingStore{MemoryTransactionStore: NewMemoryTransactionStore()}
+	worker := NewRollupWorker(store, nil, nil)
+	ctx := context.Background()
+
+	inserted := []models.Transaction{
+		transaction(alice, "r1", "Wager", "1", "USD", "1", date("2024-03-01T10:00:00Z")),
+		transaction(bob, "r2", "Wager", "1", "USD", "1", date("2024-03-02T10:00:00Z")),
+	}
+
+	// Inserts are added incrementally without rebuilding anything
+	if err := worker.apply(ctx, TransactionChanges{Inserted: inserted}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if len(store.added) != 2 || len(store.rebuilt) != 0 {
+		t.Fatalf("got %d added and %d rebuilt days, want 2 and 0", len(store.added), len(store.rebuilt))
+	}
+
+	// A changed transaction rebuilds its day, which then covers the
+	// batch's inserts into that day
+	store.added = nil
+	changes := TransactionChanges{Inserted: inserted, Rebuild: []time.Time{date("2024-03-02T18:00:00Z")}}
+	if err := worker.apply(ctx, changes); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if len(store.rebuilt) != 1 || !store.rebuilt[0].Equal(date("2024-03-02T00:00:00Z")) {
+		t.Fatalf("got rebuilt days %v, want 2024-03-02", store.rebuilt)
+	}
+	if len(store.added) != 1 || store.added[0].UserID != alice {
+		t.Fatalf("got %d added, want only the insert outside the rebuilt day", len(store.added))
+	}
+
+	// A failed batch is rebuilt rather than added again when redelivered
+	store.added, store.rebuilt, store.failAdd = nil, nil, true
+	if err := worker.apply(ctx, TransactionChanges{Inserted: inserted[:1]}); err == nil {
+		t.Fatal("apply: got no error from a failing store")
+	}
+	store.failAdd = false
+	if err := worker.apply(ctx, TransactionChanges{Inserted: inserted[:1]}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if len(store.added) != 0 || len(store.rebuilt) != 1 || !store.rebuilt[0].Equal(date("2024-03-01T00:00:00Z")) {
+		t.Fatalf("got %d added and rebuilt days %v, want the failed day rebuilt", len(store.added), store.rebuilt)
+	}
+
+	// Once rebuilt, the day is maintained incrementally again
+	store.added, store.rebuilt = nil, nil
+	if err := worker.apply(ctx, TransactionChanges{Inserted: inserted[:1]}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if len(store.added) != 1 || len(store.rebuilt) != 0 {
+		t.Fatalf("got %d added and %d rebuilt days, want 1 and 0", len(store.added), len(store.rebuilt))
+	}
+}
+
+// bootstrapFeed is a feed without a saved position: it bootstraps and then
+// stops the worker
+type bootstrapFeed struct {
+	stop func()
+	err  error
+}
+
+func (f *bootstrapFeed) Run(ctx context.Context, bootstrap func(ctx context.Context) error, handle func(ctx context.Context, changes TransactionChanges) error) error {
+	defer f.stop()
+	f.err = bootstrap(ctx)
+	return f.err
+}
+
+func TestRollupWorkerBootstrap(t *testing.T) {
+	// Transactions stored before rollups existed
+	store := NewMemoryTransactionStore()
+	store.Add(
+		transaction(alice, "r1", "Wager", "1", "USD", "1", date("2024-03-01T10:00:00Z")),
+		transaction(bob, "r2", "Wager", "2", "USD", "2", time.Now().UTC()),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	feed := &bootstrapFeed{stop: cancel}
+	NewRollupWorker(store, nil, feed).Run(ctx)
+	if feed.err != nil {
+		t.Fatalf("bootstrap: %v", feed.err)
+	}
+
+	stats, _ := store.DailyStats(context.Background(), date("2024-03-01T00:00:00Z"), truncateToDay(time.Now()).AddDate(0, 0, 1))
+	if len(stats) != 2 || stats[0].Amount.Cmp(decimal("1")) != 0 || stats[1].Amount.Cmp(decimal("2")) != 0 {
+		t.Errorf("got %+v, want the rollups of both days", stats)
+	}
+}
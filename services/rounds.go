@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"admin_statistics_api/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrRoundNotFound = errors.New("round not found")
+
+// Problems reported for rounds that do not settle cleanly
+const (
+	RoundIssueMissingWager     = "missing wager"
+	RoundIssueMissingPayout    = "missing payout"
+	RoundIssueMultipleWagers   = "multiple wagers"
+	RoundIssueCurrencyMismatch = "currency mismatch"
+	RoundIssueUserMismatch     = "user mismatch"
+)
+
+// RoundService reports on individual game rounds
+type RoundService struct {
+	store TransactionStore
+}
+
+func NewRoundService(store TransactionStore) *RoundService {
+	return &RoundService{
+		store: store,
+	}
+}
+
+// GetRound returns the wager, payouts and outcome of a round
+func (s *RoundService) GetRound(ctx context.Context, roundID string) (*models.RoundDetail, error) {
+	txs, err := s.store.RoundTransactions(ctx, roundID)
+	if err != nil {
+		return nil, err
+	}
+	if len(txs) == 0 {
+		return nil, ErrRoundNotFound
+	}
+
+	detail := buildRoundDetail(roundID, txs)
+	return &detail, nil
+}
+
+// GetUnsettledRounds lists rounds touching [from, to] that are missing a
+// wager or payout or whose transactions disagree on currency or user
+func (s *RoundService) GetUnsettledRounds(ctx context.Context, from, to time.Time, limit int) ([]models.RoundDetail, error) {
+	rounds, err := s.store.UnsettledRounds(ctx, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]models.RoundDetail, 0, len(rounds))
+	for _, round := range rounds {
+		details = append(details, buildRoundDetail(round.RoundID, round.Transactions))
+	}
+	return details, nil
+}
+
+// roundIssues lists what is wrong with a round's transactions
+func roundIssues(txs []models.Transaction) []string {
+	var wagers, payouts int
+	currencies := make(map[string]bool)
+	users := make(map[primitive.ObjectID]bool)
+	for _, tx := range txs {
+		switch tx.Type {
+		case "Wager":
+			wagers++
+		case "Payout":
+			payouts++
+		}
+		currencies[tx.Currency] = true
+		users[tx.UserID] = true
+	}
+
+	var issues []string
+	switch {
+	case wagers == 0:
+		issues = append(issues, RoundIssueMissingWager)
+	case wagers > 1:
+		issues = append(issues, RoundIssueMultipleWagers)
+	}
+	if payouts == 0 {
+		issues = append(issues, RoundIssueMissingPayout)
+	}
+	if len(currencies) > 1 {
+		issues = append(issues, RoundIssueCurrencyMismatch)
+	}
+	if len(users) > 1 {
+		issues = append(issues, RoundIssueUserMismatch)
+	}
+	return issues
+}
+
+// buildRoundDetail summarises transactions sorted by creation time
+func buildRoundDetail(roundID string, txs []models.Transaction) models.RoundDetail {
+	detail := models.RoundDetail{
+		RoundID: roundID,
+		Payouts: []models.Transaction{},
+		Issues:  roundIssues(txs),
+	}
+	detail.Settled = len(detail.Issues) == 0
+
+	var wagerUSD, payoutUSD models.Decimal
+	var lastPayout time.Time
+	for i := range txs {
+		tx := txs[i]
+		switch tx.Type {
+		case "Wager":
+			if detail.Wager == nil {
+				detail.Wager = &tx
+			}
+			detail.WagerAmount = detail.WagerAmount.Add(models.DecimalFrom128(tx.Amount))
+			wagerUSD = wagerUSD.Add(models.DecimalFrom128(tx.USDAmount))
+		case "Payout":
+			detail.Payouts = append(detail.Payouts, tx)
+			detail.PayoutAmount = detail.PayoutAmount.Add(models.DecimalFrom128(tx.Amount))
+			payoutUSD = payoutUSD.Add(models.DecimalFrom128(tx.USDAmount))
+			lastPayout = tx.CreatedAt
+		}
+	}
+
+	// User and currency come from the wager when there is one
+	first := txs[0]
+	if detail.Wager != nil {
+		first = *detail.Wager
+	}
+	detail.UserID = first.UserID.Hex()
+	detail.Currency = first.Currency
+
+	if detail.WagerAmount.IsPositive() {
+		detail.Multiplier = detail.PayoutAmount.Ratio(detail.WagerAmount)
+	}
+	if detail.Wager != nil && len(detail.Payouts) > 0 {
+		elapsed := lastPayout.Sub(detail.Wager.CreatedAt).Seconds()
+		detail.ElapsedSeconds = &elapsed
+	}
+	detail.NetResult = detail.PayoutAmount.Sub(detail.WagerAmount)
+	detail.NetResultUSD = payoutUSD.Sub(wagerUSD)
+
+	return detail
+}
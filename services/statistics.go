@@ -3,323 +3,301 @@ package services
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"strconv"
 	"time"
 
-	"admin_statistics_api/config"
 	"admin_statistics_api/models"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// cacheTTL is how long computed statistics stay in the cache
+const cacheTTL = 5 * time.Minute
+
+var (
+	ErrNoWagerData    = errors.New("no wager data found for the specified time period")
+	ErrUserNotFound   = errors.New("user not found in wager data for the specified time period")
+	ErrNoUserActivity = errors.New("no activity found for the user in the specified time period")
 )
 
 type StatisticsService struct {
-	collection *mongo.Collection
+	store TransactionStore
+	cache Cache
+	rates RateProvider
 }
 
-func NewStatisticsService() *StatisticsService {
+// NewStatisticsService creates a service backed by the given store. The
+// cache is optional and may be nil; rates are used to revalue results.
+func NewStatisticsService(store TransactionStore, cache Cache, rates RateProvider) *StatisticsService {
 	return &StatisticsService{
-		collection: config.DB.Collection("transactions"),
+		store: store,
+		cache: cache,
+		rates: rates,
 	}
 }
 
-// GetGrossGamingRevenue calculates GGR (Wagers - Payouts) by currency
-func (s *StatisticsService) GetGrossGamingRevenue(from, to time.Time) ([]models.GrossGamingRevenue, error) {
-	ctx := context.Background()
-	
-	// Try to get from cache first
-	cacheKey := fmt.Sprintf("ggr:%d:%d", from.Unix(), to.Unix())
-	if cached, err := config.GetCache(cacheKey); err == nil {
-		var result []models.GrossGamingRevenue
-		if json.Unmarshal([]byte(cached), &result) == nil {
-			return result, nil
-		}
+// getCached decodes a cached JSON value into dest, reporting whether it was found
+func (s *StatisticsService) getCached(ctx context.Context, key string, dest interface{}) bool {
+	if s.cache == nil {
+		return false
 	}
-
-	pipeline := []bson.M{
-		{
-			"$match": bson.M{
-				"createdAt": bson.M{
-					"$gte": from,
-					"$lte": to,
-				},
-			},
-		},
-		{
-			"$group": bson.M{
-				"_id": bson.M{
-					"currency": "$currency",
-					"type":     "$type",
-				},
-				"totalAmount":    bson.M{"$sum": bson.M{"$toDouble": "$amount"}},
-				"totalUSDAmount": bson.M{"$sum": bson.M{"$toDouble": "$usdAmount"}},
-			},
-		},
-		{
-			"$group": bson.M{
-				"_id": "$_id.currency",
-				"wagers": bson.M{
-					"$sum": bson.M{
-						"$cond": bson.A{
-							bson.M{"$eq": bson.A{"$_id.type", "Wager"}},
-							"$totalAmount",
-							0,
-						},
-					},
-				},
-				"payouts": bson.M{
-					"$sum": bson.M{
-						"$cond": bson.A{
-							bson.M{"$eq": bson.A{"$_id.type", "Payout"}},
-							"$totalAmount",
-							0,
-						},
-					},
-				},
-				"wagersUSD": bson.M{
-					"$sum": bson.M{
-						"$cond": bson.A{
-							bson.M{"$eq": bson.A{"$_id.type", "Wager"}},
-							"$totalUSDAmount",
-							0,
-						},
-					},
-				},
-				"payoutsUSD": bson.M{
-					"$sum": bson.M{
-						"$cond": bson.A{
-							bson.M{"$eq": bson.A{"$_id.type", "Payout"}},
-							"$totalUSDAmount",
-							0,
-						},
-					},
-				},
-			},
-		},
-		{
-			"$project": bson.M{
-				"currency": "$_id",
-				"ggr":      bson.M{"$subtract": bson.A{"$wagers", "$payouts"}},
-				"ggrUSD":   bson.M{"$subtract": bson.A{"$wagersUSD", "$payoutsUSD"}},
-			},
-		},
+	cached, err := s.cache.Get(ctx, key)
+	if err != nil {
+		return false
 	}
+	return json.Unmarshal([]byte(cached), dest) == nil
+}
 
-	cursor, err := s.collection.Aggregate(ctx, pipeline)
-	if err != nil {
-		return nil, err
+// setCached stores value as JSON, ignoring cache failures
+func (s *StatisticsService) setCached(ctx context.Context, key string, value interface{}) {
+	if s.cache == nil {
+		return
+	}
+	if valueJSON, err := json.Marshal(value); err == nil {
+		s.cache.Set(ctx, key, string(valueJSON), cacheTTL)
 	}
-	defer cursor.Close(ctx)
+}
 
+// GetGrossGamingRevenue calculates GGR (Wagers - Payouts) by currency
+func (s *StatisticsService) GetGrossGamingRevenue(ctx context.Context, from, to time.Time) ([]models.GrossGamingRevenue, error) {
+	// Try to get from cache first
+	cacheKey := statsCacheKey(ggrCachePrefix, from, to)
 	var results []models.GrossGamingRevenue
-	for cursor.Next(ctx) {
-		var doc struct {
-			Currency string  `bson:"currency"`
-			GGR      float64 `bson:"ggr"`
-			GGRUSD   float64 `bson:"ggrUSD"`
+	if s.getCached(ctx, cacheKey, &results) {
+		return results, nil
+	}
+
+	// Whole days are answered from the daily_stats rollups and only the
+	// partial days at either edge scan raw transactions
+	days := splitWholeDays(from, to)
+	var parts [][]models.GrossGamingRevenue
+	if days.hasWholeDays() {
+		stats, err := s.store.DailyStats(ctx, days.wholeFrom, days.wholeTo)
+		if err != nil {
+			return nil, err
 		}
-		if err := cursor.Decode(&doc); err != nil {
-			continue
+		parts = append(parts, ggrFromDailyStats(stats))
+	}
+	for _, edge := range days.edges {
+		part, err := s.store.GrossGamingRevenue(ctx, edge[0], edge[1])
+		if err != nil {
+			return nil, err
 		}
-		results = append(results, models.GrossGamingRevenue{
-			Currency: doc.Currency,
-			Amount:   doc.GGR,
-			USDValue: doc.GGRUSD,
-		})
+		parts = append(parts, part)
 	}
+	results = mergeGrossGamingRevenue(parts...)
 
-	// Cache the result for 5 minutes
-	if resultJSON, err := json.Marshal(results); err == nil {
-		config.SetCache(cacheKey, string(resultJSON), 5*time.Minute)
-	}
+	s.setCached(ctx, cacheKey, results)
 
 	return results, nil
 }
 
-// GetDailyWagerVolume calculates daily wager volume by currency
-func (s *StatisticsService) GetDailyWagerVolume(from, to time.Time) ([]models.DailyWagerVolume, error) {
-	ctx := context.Background()
-	
+// GetWagerVolume calculates wager volume by currency in time buckets of the
+// given granularity. Bucket boundaries are taken in loc and bucket starts
+// are reported in it.
+func (s *StatisticsService) GetWagerVolume(ctx context.Context, from, to time.Time, granularity string, loc *time.Location) ([]models.WagerVolume, error) {
+	if err := checkGranularity(granularity, from, to); err != nil {
+		return nil, err
+	}
+
 	// Try to get from cache first
-	cacheKey := fmt.Sprintf("daily_wager:%d:%d", from.Unix(), to.Unix())
-	if cached, err := config.GetCache(cacheKey); err == nil {
-		var result []models.DailyWagerVolume
-		if json.Unmarshal([]byte(cached), &result) == nil {
-			return result, nil
-		}
+	cacheKey := statsCacheKey(wagerVolumeCachePrefix, from, to, granularity, loc.String())
+	var results []models.WagerVolume
+	if s.getCached(ctx, cacheKey, &results) {
+		return results, nil
 	}
 
-	pipeline := []bson.M{
-		{
-			"$match": bson.M{
-				"createdAt": bson.M{
-					"$gte": from,
-					"$lte": to,
-				},
-				"type": "Wager",
-			},
-		},
-		{
-			"$group": bson.M{
-				"_id": bson.M{
-					"date": bson.M{
-						"$dateToString": bson.M{
-							"format": "%Y-%m-%d",
-							"date":   "$createdAt",
-						},
-					},
-					"currency": "$currency",
-				},
-				"totalAmount":    bson.M{"$sum": bson.M{"$toDouble": "$amount"}},
-				"totalUSDAmount": bson.M{"$sum": bson.M{"$toDouble": "$usdAmount"}},
-			},
-		},
-		{
-			"$project": bson.M{
-				"date":     "$_id.date",
-				"currency": "$_id.currency",
-				"amount":   "$totalAmount",
-				"usdValue": "$totalUSDAmount",
-			},
-		},
-		{
-			"$sort": bson.M{
-				"date":     1,
-				"currency": 1,
-			},
-		},
+	// Buckets of a day or longer are built from the daily_stats rollups
+	// and only the partial days at either edge scan raw transactions.
+	// Minute and hour buckets, and buckets outside UTC, always come from
+	// raw transactions.
+	days := splitWholeDays(from, to)
+	if !usesDailyRollups(granularity, loc) {
+		days = dayRange{edges: [][2]time.Time{{from, to}}}
+	}
+	var parts [][]models.WagerVolume
+	if days.hasWholeDays() {
+		stats, err := s.store.DailyStats(ctx, days.wholeFrom, days.wholeTo)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, volumeFromDailyStats(stats, granularity))
+	}
+	for _, edge := range days.edges {
+		part, err := s.store.WagerVolume(ctx, edge[0], edge[1], granularity, loc)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
 	}
+	results = mergeWagerVolume(parts...)
+	for i := range results {
+		results[i].Bucket = results[i].Bucket.In(loc)
+	}
+
+	s.setCached(ctx, cacheKey, results)
 
-	cursor, err := s.collection.Aggregate(ctx, pipeline)
+	return results, nil
+}
+
+// GetDailyWagerVolume calculates daily wager volume by currency, with days
+// taken in loc
+func (s *StatisticsService) GetDailyWagerVolume(ctx context.Context, from, to time.Time, loc *time.Location) ([]models.DailyWagerVolume, error) {
+	volumes, err := s.GetWagerVolume(ctx, from, to, GranularityDay, loc)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(ctx)
+	return dailyWagerVolume(volumes, loc), nil
+}
 
+// dailyWagerVolume converts day buckets into the daily volume shape
+func dailyWagerVolume(volumes []models.WagerVolume, loc *time.Location) []models.DailyWagerVolume {
 	var results []models.DailyWagerVolume
-	for cursor.Next(ctx) {
-		var doc struct {
-			Date     string  `bson:"date"`
-			Currency string  `bson:"currency"`
-			Amount   float64 `bson:"amount"`
-			USDValue float64 `bson:"usdValue"`
-		}
-		if err := cursor.Decode(&doc); err != nil {
-			continue
-		}
+	for _, volume := range volumes {
 		results = append(results, models.DailyWagerVolume{
-			Date:     doc.Date,
-			Currency: doc.Currency,
-			Amount:   doc.Amount,
-			USDValue: doc.USDValue,
+			Date:     volume.Bucket.In(loc).Format("2006-01-02"),
+			Currency: volume.Currency,
+			Amount:   volume.Amount,
+			USDValue: volume.USDValue,
 		})
 	}
+	return results
+}
 
-	// Cache the result for 5 minutes
-	if resultJSON, err := json.Marshal(results); err == nil {
-		config.SetCache(cacheKey, string(resultJSON), 5*time.Minute)
+// GetUserWagerPercentile calculates user's wager percentile.
+//
+// Ties use standard competition ranking ("1224"): users with the same total
+// share the best rank of the group and the next rank skips accordingly. The
+// percentile is then the share of users whose total is at or below the
+// user's, so tied users always get the same rank and percentile.
+func (s *StatisticsService) GetUserWagerPercentile(ctx context.Context, userID primitive.ObjectID, from, to time.Time) (*models.UserWagerPercentile, error) {
+	// Try to get from cache first
+	cacheKey := statsCacheKey(userPercentileCachePrefix, from, to, userID.Hex())
+	var cached models.UserWagerPercentile
+	if s.getCached(ctx, cacheKey, &cached) {
+		return &cached, nil
 	}
 
-	return results, nil
-}
+	// Rank and tie counts are computed by the store; only the target
+	// user's row and the number of ranked users come back
+	rank, totalUsers, err := s.store.UserWagerRank(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
 
-// GetUserWagerPercentile calculates user's wager percentile
-func (s *StatisticsService) GetUserWagerPercentile(userID primitive.ObjectID, from, to time.Time) (*models.UserWagerPercentile, error) {
-	ctx := context.Background()
-	
-	// Try to get from cache first
-	cacheKey := fmt.Sprintf("user_percentile:%s:%d:%d", userID.Hex(), from.Unix(), to.Unix())
-	if cached, err := config.GetCache(cacheKey); err == nil {
-		var result models.UserWagerPercentile
-		if json.Unmarshal([]byte(cached), &result) == nil {
-			return &result, nil
-		}
+	if totalUsers == 0 {
+		return nil, ErrNoWagerData
 	}
 
-	// First, get all users' total wager amounts
-	pipeline := []bson.M{
-		{
-			"$match": bson.M{
-				"createdAt": bson.M{
-					"$gte": from,
-					"$lte": to,
-				},
-				"type": "Wager",
-			},
-		},
-		{
-			"$group": bson.M{
-				"_id":              "$userId",
-				"totalWageredUSD": bson.M{"$sum": bson.M{"$toDouble": "$usdAmount"}},
-			},
-		},
-		{
-			"$sort": bson.M{
-				"totalWageredUSD": -1,
-			},
-		},
+	if rank == nil {
+		return nil, ErrUserNotFound
 	}
 
-	cursor, err := s.collection.Aggregate(ctx, pipeline)
+	result := &models.UserWagerPercentile{
+		UserID:       userID.Hex(),
+		TotalWagered: rank.TotalWageredUSD,
+		Percentile:   percentileForRank(rank.Rank, totalUsers),
+		Rank:         rank.Rank,
+		TotalUsers:   totalUsers,
+		TiedUsers:    rank.TiedUsers,
+	}
+
+	s.setCached(ctx, cacheKey, result)
+
+	return result, nil
+}
+
+// GetUserSummary summarises a user's wagers, payouts and rounds per currency
+func (s *StatisticsService) GetUserSummary(ctx context.Context, userID primitive.ObjectID, from, to time.Time) (*models.UserSummary, error) {
+	// Try to get from cache first
+	cacheKey := statsCacheKey(userSummaryCachePrefix, from, to, userID.Hex())
+	var cached models.UserSummary
+	if s.getCached(ctx, cacheKey, &cached) {
+		return &cached, nil
+	}
+
+	byCurrency, err := s.store.UserCurrencySummaries(ctx, userID, from, to)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(ctx)
 
-	var userWagers []struct {
-		UserID           primitive.ObjectID `bson:"_id"`
-		TotalWageredUSD float64            `bson:"totalWageredUSD"`
+	if len(byCurrency) == 0 {
+		return nil, ErrNoUserActivity
 	}
 
-	for cursor.Next(ctx) {
-		var doc struct {
-			UserID           primitive.ObjectID `bson:"_id"`
-			TotalWageredUSD float64            `bson:"totalWageredUSD"`
+	result := &models.UserSummary{
+		UserID:        userID.Hex(),
+		Currencies:    make([]string, 0, len(byCurrency)),
+		FirstActivity: byCurrency[0].FirstActivity,
+		LastActivity:  byCurrency[0].LastActivity,
+		ByCurrency:    byCurrency,
+	}
+	for _, summary := range byCurrency {
+		result.Currencies = append(result.Currencies, summary.Currency)
+		result.RoundCount += summary.RoundCount
+		result.TotalWageredUSD = result.TotalWageredUSD.Add(summary.WageredUSD)
+		result.TotalPaidOutUSD = result.TotalPaidOutUSD.Add(summary.PaidOutUSD)
+		if summary.FirstActivity.Before(result.FirstActivity) {
+			result.FirstActivity = summary.FirstActivity
 		}
-		if err := cursor.Decode(&doc); err != nil {
-			continue
+		if summary.LastActivity.After(result.LastActivity) {
+			result.LastActivity = summary.LastActivity
 		}
-		userWagers = append(userWagers, doc)
 	}
+	result.NetGGRUSD = result.TotalWageredUSD.Sub(result.TotalPaidOutUSD)
 
-	if len(userWagers) == 0 {
-		return nil, fmt.Errorf("no wager data found for the specified time period")
-	}
+	s.setCached(ctx, cacheKey, result)
 
-	// Find the target user's position and calculate percentile
-	var targetUserWager float64
-	var userRank int
-	found := false
-
-	for i, wager := range userWagers {
-		if wager.UserID == userID {
-			targetUserWager = wager.TotalWageredUSD
-			userRank = i + 1
-			found = true
-			break
-		}
-	}
+	return result, nil
+}
 
-	if !found {
-		return nil, fmt.Errorf("user not found in wager data for the specified time period")
+// GetLeaderboard ranks the top users by a metric. Ranks and percentiles use
+// the same tie policy as GetUserWagerPercentile.
+func (s *StatisticsService) GetLeaderboard(ctx context.Context, query LeaderboardQuery) (*models.Leaderboard, error) {
+	// Try to get from cache first
+	cacheKey := statsCacheKey(leaderboardCachePrefix, query.From, query.To,
+		query.Metric, query.Currency, strconv.Itoa(query.Limit))
+	var cached models.Leaderboard
+	if s.getCached(ctx, cacheKey, &cached) {
+		return &cached, nil
 	}
 
-	totalUsers := len(userWagers)
-	percentile := float64(totalUsers-userRank+1) / float64(totalUsers) * 100
-
-	result := &models.UserWagerPercentile{
-		UserID:       userID.Hex(),
-		TotalWagered: targetUserWager,
-		Percentile:   percentile,
-		Rank:         userRank,
-		TotalUsers:   totalUsers,
+	rows, totalUsers, err := s.store.Leaderboard(ctx, query)
+	if err != nil {
+		return nil, err
 	}
 
-	// Cache the result for 5 minutes
-	if resultJSON, err := json.Marshal(result); err == nil {
-		config.SetCache(cacheKey, string(resultJSON), 5*time.Minute)
+	result := &models.Leaderboard{
+		Metric:     query.Metric,
+		Currency:   query.Currency,
+		TotalUsers: totalUsers,
+		Entries:    make([]models.LeaderboardEntry, 0, len(rows)),
+	}
+	rank := 0
+	for i, row := range rows {
+		// Competition ranking, as in GetUserWagerPercentile
+		if i == 0 || row.metricValue(query.Metric).Cmp(rows[i-1].metricValue(query.Metric)) != 0 {
+			rank = i + 1
+		}
+		result.Entries = append(result.Entries, models.LeaderboardEntry{
+			Rank:       rank,
+			UserID:     row.UserID.Hex(),
+			Value:      row.metricValue(query.Metric),
+			WageredUSD: row.WageredUSD,
+			PaidOutUSD: row.PaidOutUSD,
+			NetLossUSD: row.NetLossUSD,
+			Rounds:     row.Rounds,
+			Percentile: percentileForRank(rank, totalUsers),
+		})
 	}
 
+	s.setCached(ctx, cacheKey, result)
+
 	return result, nil
-}
\ No newline at end of file
+}
+
+// percentileForRank returns the share of users ranked at or below rank
+func percentileForRank(rank, totalUsers int) float64 {
+	return float64(totalUsers-rank+1) / float64(totalUsers) * 100
+}
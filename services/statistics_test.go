@@ -0,0 +1,334 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"admin_statistics_api/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	alice = primitive.NewObjectID()
+	bob   = primitive.NewObjectID()
+	carol = primitive.NewObjectID()
+)
+
+// transaction builds a transaction with amounts given as decimal strings
+func transaction(userID primitive.ObjectID, roundID, txType, amount, currency, usdAmount string, createdAt time.Time) models.Transaction {
+	return models.Transaction{
+		CreatedAt: createdAt,
+		UserID:    userID,
+		RoundID:   roundID,
+		Type:      txType,
+		Amount:    decimal128(amount),
+		Currency:  currency,
+		USDAmount: decimal128(usdAmount),
+	}
+}
+
+func decimal128(value string) primitive.Decimal128 {
+	d, err := primitive.ParseDecimal128(value)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func decimal(value string) models.Decimal {
+	d, err := models.ParseDecimal(value)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func date(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestGetGrossGamingRevenue(t *testing.T) {
+	store := NewMemoryTransactionStore(
+		transaction(alice, "r1", "Wager", "0.1", "BTC", "4000", date("2024-03-01T10:00:00Z")),
+		transaction(alice, "r1", "Payout", "0.05", "BTC", "2000", date("2024-03-01T10:01:00Z")),
+		transaction(bob, "r2", "Wager", "100.10", "USD", "100.10", date("2024-03-02T23:59:59Z")),
+		transaction(bob, "r2", "Payout", "250.20", "USD", "250.20", date("2024-03-03T00:00:01Z")),
+		transaction(carol, "r3", "Wager", "0.3", "ETH", "900", date("2024-03-05T12:00:00Z")),
+	)
+	service := NewStatisticsService(store, nil, nil)
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     map[string][2]string
+	}{
+		{
+			name: "whole days",
+			from: date("2024-03-01T00:00:00Z"),
+			to:   date("2024-03-03T23:59:59.999Z"),
+			want: map[string][2]string{
+				"BTC": {"0.05", "2000"},
+				"USD": {"-150.10", "-150.10"},
+			},
+		},
+		{
+			name: "partial edge days",
+			from: date("2024-03-01T10:00:30Z"),
+			to:   date("2024-03-02T23:59:59Z"),
+			want: map[string][2]string{
+				"BTC": {"-0.05", "-2000"},
+				"USD": {"100.10", "100.10"},
+			},
+		},
+		{
+			name: "single instant",
+			from: date("2024-03-05T12:00:00Z"),
+			to:   date("2024-03-05T12:00:00Z"),
+			want: map[string][2]string{
+				"ETH": {"0.3", "900"},
+			},
+		},
+		{
+			name: "empty range",
+			from: date("2024-04-01T00:00:00Z"),
+			to:   date("2024-04-30T23:59:59Z"),
+			want: map[string][2]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := service.GetGrossGamingRevenue(context.Background(), tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("GetGrossGamingRevenue: %v", err)
+			}
+			if len(results) != len(tt.want) {
+				t.Fatalf("got %d currencies, want %d: %+v", len(results), len(tt.want), results)
+			}
+			for _, ggr := range results {
+				want, ok := tt.want[ggr.Currency]
+				if !ok {
+					t.Fatalf("unexpected currency %s", ggr.Currency)
+				}
+				if ggr.Amount.Cmp(decimal(want[0])) != 0 || ggr.USDValue.Cmp(decimal(want[1])) != 0 {
+					t.Errorf("%s: got %s / %s USD, want %s / %s USD",
+						ggr.Currency, ggr.Amount, ggr.USDValue, want[0], want[1])
+				}
+			}
+		})
+	}
+}
+
+func TestGetDailyWagerVolume(t *testing.T) {
+	malta, err := time.LoadLocation("Europe/Malta")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+
+	store := NewMemoryTransactionStore(
+		transaction(alice, "r1", "Wager", "10", "USD", "10", date("2024-03-01T10:00:00Z")),
+		transaction(bob, "r2", "Wager", "5.5", "USD", "5.5", date("2024-03-01T23:30:00Z")),
+		transaction(bob, "r2", "Payout", "50", "USD", "50", date("2024-03-01T23:31:00Z")),
+		transaction(carol, "r3", "Wager", "0.01", "BTC", "600", date("2024-03-02T08:00:00Z")),
+	)
+	service := NewStatisticsService(store, nil, nil)
+
+	type day struct {
+		date, currency, amount string
+	}
+	tests := []struct {
+		name string
+		loc  *time.Location
+		want []day
+	}{
+		{
+			name: "UTC",
+			loc:  time.UTC,
+			want: []day{
+				{"2024-03-01", "USD", "15.5"},
+				{"2024-03-02", "BTC", "0.01"},
+			},
+		},
+		{
+			// 23:30 UTC is already the next day in Malta
+			name: "Europe/Malta",
+			loc:  malta,
+			want: []day{
+				{"2024-03-01", "USD", "10"},
+				{"2024-03-02", "BTC", "0.01"},
+				{"2024-03-02", "USD", "5.5"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from := time.Date(2024, 3, 1, 0, 0, 0, 0, tt.loc)
+			to := time.Date(2024, 3, 3, 0, 0, 0, 0, tt.loc).Add(-time.Millisecond)
+			results, err := service.GetDailyWagerVolume(context.Background(), from, to, tt.loc)
+			if err != nil {
+				t.Fatalf("GetDailyWagerVolume: %v", err)
+			}
+			if len(results) != len(tt.want) {
+				t.Fatalf("got %d entries, want %d: %+v", len(results), len(tt.want), results)
+			}
+			for i, want := range tt.want {
+				got := results[i]
+				if got.Date != want.date || got.Currency != want.currency || got.Amount.Cmp(decimal(want.amount)) != 0 {
+					t.Errorf("entry %d: got %s %s %s, want %s %s %s",
+						i, got.Date, got.Currency, got.Amount, want.date, want.currency, want.amount)
+				}
+			}
+		})
+	}
+}
+
+func TestGetUserWagerPercentile(t *testing.T) {
+	dave := primitive.NewObjectID()
+	at := date("2024-03-01T12:00:00Z")
+	store := NewMemoryTransactionStore(
+		transaction(alice, "r1", "Wager", "300", "USD", "300", at),
+		transaction(bob, "r2", "Wager", "100", "USD", "100", at),
+		transaction(bob, "r3", "Wager", "100", "USD", "100", at),
+		transaction(carol, "r4", "Wager", "200", "USD", "200", at),
+		transaction(dave, "r5", "Payout", "1000", "USD", "1000", at),
+	)
+	service := NewStatisticsService(store, nil, nil)
+	from, to := date("2024-03-01T00:00:00Z"), date("2024-03-01T23:59:59Z")
+
+	tests := []struct {
+		name           string
+		user           primitive.ObjectID
+		wantRank       int
+		wantTied       int
+		wantPercentile float64
+		wantErr        error
+	}{
+		{name: "top", user: alice, wantRank: 1, wantTied: 0, wantPercentile: 100},
+		// Bob's two wagers tie him with Carol, so both share rank 2
+		{name: "tied over several wagers", user: bob, wantRank: 2, wantTied: 1, wantPercentile: 200.0 / 3},
+		{name: "tied", user: carol, wantRank: 2, wantTied: 1, wantPercentile: 200.0 / 3},
+		{name: "no wagers", user: dave, wantErr: ErrUserNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := service.GetUserWagerPercentile(context.Background(), tt.user, from, to)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetUserWagerPercentile: %v", err)
+			}
+			if result.Rank != tt.wantRank || result.TiedUsers != tt.wantTied || result.TotalUsers != 3 {
+				t.Errorf("got rank %d with %d tied of %d, want rank %d with %d tied of 3",
+					result.Rank, result.TiedUsers, result.TotalUsers, tt.wantRank, tt.wantTied)
+			}
+			if diff := result.Percentile - tt.wantPercentile; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("got percentile %v, want %v", result.Percentile, tt.wantPercentile)
+			}
+		})
+	}
+
+	if _, err := service.GetUserWagerPercentile(context.Background(), alice, date("2025-01-01T00:00:00Z"), date("2025-01-02T00:00:00Z")); !errors.Is(err, ErrNoWagerData) {
+		t.Errorf("empty range: got error %v, want %v", err, ErrNoWagerData)
+	}
+}
+
+func TestGetLeaderboard(t *testing.T) {
+	at := date("2024-03-01T12:00:00Z")
+	dave := primitive.NewObjectID()
+	store := NewMemoryTransactionStore(
+		transaction(alice, "r1", "Wager", "100", "USD", "100", at),
+		transaction(alice, "r1", "Payout", "30", "USD", "30", at),
+		transaction(bob, "r2", "Wager", "60", "USD", "60", at),
+		transaction(bob, "r3", "Wager", "40", "USD", "40", at),
+		transaction(carol, "r4", "Wager", "50", "USD", "50", at),
+		transaction(carol, "r4", "Wager", "10", "USD", "10", at),
+		transaction(dave, "r5", "Wager", "0.001", "BTC", "60", at),
+	)
+	service := NewStatisticsService(store, nil, nil)
+	from, to := date("2024-03-01T00:00:00Z"), date("2024-03-01T23:59:59Z")
+
+	type entry struct {
+		user primitive.ObjectID
+		rank int
+	}
+	tests := []struct {
+		name   string
+		query  LeaderboardQuery
+		want   []entry
+		total  int
+		values []string
+	}{
+		{
+			// Alice and Bob share first place; the next rank skips to 3
+			name:   "wagered with ties",
+			query:  LeaderboardQuery{Metric: MetricWagered, Limit: 10},
+			want:   []entry{{alice, 1}, {bob, 1}, {carol, 3}, {dave, 3}},
+			total:  4,
+			values: []string{"100", "100", "60", "60"},
+		},
+		{
+			name:   "rounds counts distinct rounds",
+			query:  LeaderboardQuery{Metric: MetricRounds, Limit: 10},
+			want:   []entry{{bob, 1}, {alice, 2}, {carol, 2}, {dave, 2}},
+			total:  4,
+			values: []string{"2", "1", "1", "1"},
+		},
+		{
+			name:   "currency filter and limit",
+			query:  LeaderboardQuery{Metric: MetricNetLoss, Currency: "USD", Limit: 2},
+			want:   []entry{{bob, 1}, {alice, 2}},
+			total:  3,
+			values: []string{"100", "70"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.From, tt.query.To = from, to
+			result, err := service.GetLeaderboard(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("GetLeaderboard: %v", err)
+			}
+			if result.TotalUsers != tt.total {
+				t.Errorf("got %d total users, want %d", result.TotalUsers, tt.total)
+			}
+			if len(result.Entries) != len(tt.want) {
+				t.Fatalf("got %d entries, want %d", len(result.Entries), len(tt.want))
+			}
+
+			// Users tied on a value are ordered by ID, so compare ranks and
+			// values in order and users as a set per rank
+			usersByRank := make(map[int]map[string]bool)
+			for _, want := range tt.want {
+				if usersByRank[want.rank] == nil {
+					usersByRank[want.rank] = make(map[string]bool)
+				}
+				usersByRank[want.rank][want.user.Hex()] = true
+			}
+			for i, got := range result.Entries {
+				if got.Rank != tt.want[i].rank {
+					t.Errorf("entry %d: got rank %d, want %d", i, got.Rank, tt.want[i].rank)
+				}
+				if !usersByRank[got.Rank][got.UserID] {
+					t.Errorf("entry %d: user %s does not belong at rank %d", i, got.UserID, got.Rank)
+				}
+				if got.Value.Cmp(decimal(tt.values[i])) != 0 {
+					t.Errorf("entry %d: got value %s, want %s", i, got.Value, tt.values[i])
+				}
+			}
+		})
+	}
+}
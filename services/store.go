@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"admin_statistics_api/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TransactionStore is the data access layer behind StatisticsService.
+// MongoTransactionStore runs the aggregation pipelines against MongoDB and
+// MemoryTransactionStore computes the same results in plain Go.
+type TransactionStore interface {
+	// GrossGamingRevenue returns wagers minus payouts per currency
+	GrossGamingRevenue(ctx context.Context, from, to time.Time) ([]models.GrossGamingRevenue, error)
+
+	// WagerVolume returns wager totals per currency in time buckets of the
+	// given granularity, with bucket boundaries taken in loc, sorted by
+	// bucket then currency
+	WagerVolume(ctx context.Context, from, to time.Time, granularity string, loc *time.Location) ([]models.WagerVolume, error)
+
+	// UserWagerRank ranks a user against every user that wagered in
+	// [from, to] using standard competition ranking. It returns the number
+	// of ranked users and a nil rank when the user did not wager.
+	UserWagerRank(ctx context.Context, userID primitive.ObjectID, from, to time.Time) (*UserWagerRank, int, error)
+
+	// DailyStats returns the rollups for the UTC days in [from, to). Both
+	// bounds are expected to be midnights UTC.
+	DailyStats(ctx context.Context, from, to time.Time) ([]models.DailyStat, error)
+
+	// RebuildDailyStats recomputes the rollups for the UTC days in [from, to)
+	// from the raw transactions
+	RebuildDailyStats(ctx context.Context, from, to time.Time) error
+
+	// AddToDailyStats adds newly inserted transactions to the rollups of
+	// their days. Transactions already counted, by an earlier call or by a
+	// rebuild of their day, are skipped. A failed call may have recorded
+	// some transactions as counted, so its days must be rebuilt.
+	AddToDailyStats(ctx context.Context, txs []models.Transaction) error
+
+	// InsertTransactions writes the transactions without stopping at the
+	// first failure. Documents that were rejected are returned by their
+	// index in txs; the error is reserved for failures of the whole write.
+	// Duplicates are reported as ErrDuplicateIdempotencyKey or
+	// ErrDuplicateRoundTransaction.
+	InsertTransactions(ctx context.Context, txs []models.Transaction) (map[int]error, error)
+
+	// FindByIdempotencyKeys returns the stored transactions for the given
+	// keys, indexed by key
+	FindByIdempotencyKeys(ctx context.Context, keys []string) (map[string]models.Transaction, error)
+
+	// RoundTransactions returns every transaction of a round ordered by
+	// creation time
+	RoundTransactions(ctx context.Context, roundID string) ([]models.Transaction, error)
+
+	// UnsettledRounds returns up to limit rounds with a transaction in
+	// [from, to] that, looking at all of their transactions, lack a wager
+	// or payout, have several wagers, or mix currencies or users. Rounds
+	// are ordered by their first transaction.
+	UnsettledRounds(ctx context.Context, from, to time.Time, limit int) ([]RoundTransactions, error)
+
+	// UserCurrencySummaries returns a user's activity in [from, to] per
+	// currency, sorted by currency
+	UserCurrencySummaries(ctx context.Context, userID primitive.ObjectID, from, to time.Time) ([]models.UserCurrencySummary, error)
+
+	// Leaderboard returns the top users for a metric together with the
+	// number of users that had any activity matching the query
+	Leaderboard(ctx context.Context, query LeaderboardQuery) ([]LeaderboardRow, int, error)
+
+	// StreamTransactions calls fn for every transaction matching the query
+	// in (createdAt, _id) order, stopping at the first error fn returns.
+	// Transactions are read one at a time and never held in memory
+	// together.
+	StreamTransactions(ctx context.Context, query TransactionQuery, fn func(models.Transaction) error) error
+}
+
+// TransactionQuery selects the transactions created in [From, To]. UserID,
+// Currency and Type are optional filters; After resumes an export right
+// after the given position.
+type TransactionQuery struct {
+	From     time.Time
+	To       time.Time
+	UserID   *primitive.ObjectID
+	Currency string
+	Type     string
+	After    *TransactionPosition
+}
+
+// TransactionPosition is a transaction's place in the (createdAt, _id)
+// export order
+type TransactionPosition struct {
+	CreatedAt time.Time
+	ID        primitive.ObjectID
+}
+
+// Leaderboard metrics
+const (
+	MetricWagered = "wagered"
+	MetricWon     = "won"
+	MetricNetLoss = "net_loss"
+	MetricRounds  = "rounds"
+)
+
+// LeaderboardQuery selects the users ranked by Leaderboard. Currency is
+// optional and restricts the transactions considered.
+type LeaderboardQuery struct {
+	Metric   string
+	Currency string
+	From     time.Time
+	To       time.Time
+	Limit    int
+}
+
+// LeaderboardRow is a user's totals as ranked by the store
+type LeaderboardRow struct {
+	UserID     primitive.ObjectID `bson:"_id"`
+	WageredUSD models.Decimal     `bson:"wageredUSD"`
+	PaidOutUSD models.Decimal     `bson:"paidOutUSD"`
+	NetLossUSD models.Decimal     `bson:"netLossUSD"`
+	// Rounds counts the distinct rounds the user wagered in
+	Rounds int `bson:"rounds"`
+}
+
+// metricValue returns the value a row is ranked by
+func (r LeaderboardRow) metricValue(metric string) models.Decimal {
+	switch metric {
+	case MetricWon:
+		return r.PaidOutUSD
+	case MetricNetLoss:
+		return r.NetLossUSD
+	case MetricRounds:
+		return models.DecimalFromInt(int64(r.Rounds))
+	default:
+		return r.WageredUSD
+	}
+}
+
+// RoundTransactions groups the transactions that share a roundId
+type RoundTransactions struct {
+	RoundID      string               `bson:"_id"`
+	Transactions []models.Transaction `bson:"transactions"`
+}
+
+var (
+	ErrDuplicateIdempotencyKey   = errors.New("a transaction with this idempotency key already exists")
+	ErrDuplicateRoundTransaction = errors.New("the round already has a transaction of this type")
+)
+
+// UserWagerRank is a user's wagered USD and position among all users.
+// TiedUsers counts the other users with exactly the same total.
+type UserWagerRank struct {
+	TotalWageredUSD models.Decimal `bson:"totalWageredUSD"`
+	Rank            int            `bson:"rank"`
+	TiedUsers       int            `bson:"tiedUsers"`
+}
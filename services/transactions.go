@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"admin_statistics_api/models"
+	"admin_statistics_api/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TransactionService writes transactions pushed by game servers, converting
+// amounts to USD with the rates in effect when they were created
+type TransactionService struct {
+	store      TransactionStore
+	rates      RateProvider
+	currencies *CurrencyRegistry
+}
+
+func NewTransactionService(store TransactionStore, rates RateProvider, currencies *CurrencyRegistry) *TransactionService {
+	return &TransactionService{
+		store:      store,
+		rates:      rates,
+		currencies: currencies,
+	}
+}
+
+// maxCreatedAtSkew is how far in the future createdAt may lie, absorbing
+// clock skew between game servers and this API. Later transactions would
+// fall outside every rollup and reporting period until that time.
+const maxCreatedAtSkew = 5 * time.Minute
+
+// BuildTransaction converts an ingestion request into a Transaction,
+// parsing identifiers and amounts and filling in createdAt and usdAmount
+// when absent. usdAmount is converted at the USD rate in effect at
+// createdAt. The request must have passed tag validation, so that unknown
+// currencies and types are reported before any rate is looked up.
+func (s *TransactionService) BuildTransaction(ctx context.Context, req models.TransactionRequest) (models.Transaction, error) {
+	userID, err := primitive.ObjectIDFromHex(req.UserID)
+	if err != nil {
+		return models.Transaction{}, fmt.Errorf("userId must be a valid MongoDB ObjectID")
+	}
+
+	amount, err := parseAmount("amount", req.Amount)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	now := time.Now().UTC()
+	createdAt := now
+	if req.CreatedAt != nil {
+		createdAt = req.CreatedAt.UTC()
+		if createdAt.After(now.Add(maxCreatedAtSkew)) {
+			return models.Transaction{}, fmt.Errorf("createdAt must not be more than %v in the future", maxCreatedAtSkew)
+		}
+	}
+
+	var usdAmount primitive.Decimal128
+	if req.USDAmount != "" {
+		usdAmount, err = parseAmount("usdAmount", req.USDAmount)
+	} else {
+		usdAmount, err = ConvertToUSD(ctx, s.rates, amount, req.Currency, createdAt, s.currencies.Decimals(QuoteUSD))
+	}
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	tx := models.Transaction{
+		CreatedAt:      createdAt,
+		UserID:         userID,
+		RoundID:        req.RoundID,
+		Type:           req.Type,
+		Amount:         amount,
+		Currency:       req.Currency,
+		USDAmount:      usdAmount,
+		IdempotencyKey: req.IdempotencyKey,
+	}
+
+	// An explicitly allowed extra payout gets a slot of its own so the
+	// round uniqueness index does not reject it
+	if req.AllowMultiplePayouts && req.Type == "Payout" {
+		tx.ID = primitive.NewObjectID()
+		tx.RoundSlot = tx.ID.Hex()
+	}
+
+	return tx, nil
+}
+
+// parseAmount parses a non-negative, finite Decimal128 amount
+func parseAmount(field string, value json.Number) (primitive.Decimal128, error) {
+	if value == "" {
+		return primitive.Decimal128{}, fmt.Errorf("%s is required", field)
+	}
+
+	amount, err := primitive.ParseDecimal128(value.String())
+	if err != nil || amount.IsNaN() || amount.IsInf() != 0 {
+		return primitive.Decimal128{}, fmt.Errorf("%s must be a valid decimal number", field)
+	}
+
+	if utils.DecimalToFloat(amount) < 0 {
+		return primitive.Decimal128{}, fmt.Errorf("%s must not be negative", field)
+	}
+
+	return amount, nil
+}
+
+// Outcomes of storing a transaction
+const (
+	IngestCreated  = "created"
+	IngestReplayed = "replayed"
+	IngestConflict = "conflict"
+	IngestFailed   = "failed"
+)
+
+// IngestResult is the outcome of storing one transaction. Transaction is
+// the stored document: the new one when created, the original one on a
+// replay or the conflicting one when known.
+type IngestResult struct {
+	Status      string
+	Transaction *models.Transaction
+	Err         error
+}
+
+// Create stores the transactions, assigning IDs to any that lack one.
+// A transaction whose idempotency key is already stored with the same
+// payload is a replay and yields the original; a different payload under
+// the same key, or a second transaction of the same type in a round, is a
+// conflict.
+func (s *TransactionService) Create(ctx context.Context, txs []models.Transaction) ([]IngestResult, error) {
+	results := make([]IngestResult, len(txs))
+
+	var keys []string
+	for i := range txs {
+		if txs[i].ID.IsZero() {
+			txs[i].ID = primitive.NewObjectID()
+		}
+		if txs[i].IdempotencyKey != "" {
+			keys = append(keys, txs[i].IdempotencyKey)
+		}
+	}
+
+	// Resolve keys that were already stored without attempting the write
+	existing, err := s.store.FindByIdempotencyKeys(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []models.Transaction
+	var indexes []int
+	for i := range txs {
+		if stored, ok := existing[txs[i].IdempotencyKey]; ok && txs[i].IdempotencyKey != "" {
+			results[i] = replayResult(txs[i], stored)
+			continue
+		}
+		pending = append(pending, txs[i])
+		indexes = append(indexes, i)
+	}
+
+	failed, err := s.store.InsertTransactions(ctx, pending)
+	if err != nil {
+		return nil, err
+	}
+
+	for j, i := range indexes {
+		insertErr, ok := failed[j]
+		if !ok {
+			tx := pending[j]
+			results[i] = IngestResult{Status: IngestCreated, Transaction: &tx}
+			continue
+		}
+		results[i] = s.resolveDuplicate(ctx, pending[j], insertErr)
+	}
+
+	return results, nil
+}
+
+// resolveDuplicate looks up the stored document behind a failed insert
+func (s *TransactionService) resolveDuplicate(ctx context.Context, tx models.Transaction, insertErr error) IngestResult {
+	switch insertErr {
+	case ErrDuplicateIdempotencyKey:
+		// Another request with the same key won the race
+		existing, err := s.store.FindByIdempotencyKeys(ctx, []string{tx.IdempotencyKey})
+		if err != nil {
+			return IngestResult{Status: IngestFailed, Err: err}
+		}
+		if stored, ok := existing[tx.IdempotencyKey]; ok {
+			return replayResult(tx, stored)
+		}
+		return IngestResult{Status: IngestConflict, Err: insertErr}
+
+	case ErrDuplicateRoundTransaction:
+		round, err := s.store.RoundTransactions(ctx, tx.RoundID)
+		if err != nil {
+			return IngestResult{Status: IngestFailed, Err: err}
+		}
+		for i := range round {
+			if round[i].Type == tx.Type && round[i].RoundSlot == tx.RoundSlot {
+				return IngestResult{Status: IngestConflict, Transaction: &round[i], Err: insertErr}
+			}
+		}
+		return IngestResult{Status: IngestConflict, Err: insertErr}
+
+	default:
+		return IngestResult{Status: IngestFailed, Err: insertErr}
+	}
+}
+
+// replayResult compares a resubmitted transaction with the stored one
+func replayResult(tx, stored models.Transaction) IngestResult {
+	if !samePayload(tx, stored) {
+		return IngestResult{
+			Status:      IngestConflict,
+			Transaction: &stored,
+			Err:         fmt.Errorf("idempotency key %q was already used for a different transaction", tx.IdempotencyKey),
+		}
+	}
+	return IngestResult{Status: IngestReplayed, Transaction: &stored}
+}
+
+// samePayload reports whether two transactions carry the same client
+// supplied data. Server-filled fields such as the ID are ignored.
+func samePayload(a, b models.Transaction) bool {
+	return a.UserID == b.UserID &&
+		a.RoundID == b.RoundID &&
+		a.Type == b.Type &&
+		a.Currency == b.Currency &&
+		a.Amount.String() == b.Amount.String()
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"admin_statistics_api/models"
+)
+
+func TestTransactionServiceCreate(t *testing.T) {
+	at := date("2024-03-01T12:00:00Z")
+	keyed := func(tx models.Transaction, key string) models.Transaction {
+		tx.IdempotencyKey = key
+		return tx
+	}
+	stored := keyed(transaction(alice, "r1", "Wager", "10", "USD", "10", at), "key-1")
+
+	tests := []struct {
+		name       string
+		tx         models.Transaction
+		wantStatus string
+		wantStored int
+	}{
+		{
+			name:       "new transaction",
+			tx:         keyed(transaction(bob, "r2", "Wager", "5", "USD", "5", at), "key-2"),
+			wantStatus: IngestCreated,
+			wantStored: 2,
+		},
+		{
+			name:       "replay with the same payload",
+			tx:         keyed(transaction(alice, "r1", "Wager", "10", "USD", "10", at), "key-1"),
+			wantStatus: IngestReplayed,
+			wantStored: 1,
+		},
+		{
+			name:       "same key with a different amount",
+			tx:         keyed(transaction(alice, "r1", "Wager", "11", "USD", "11", at), "key-1"),
+			wantStatus: IngestConflict,
+			wantStored: 1,
+		},
+		{
+			name:       "second wager in a round",
+			tx:         transaction(alice, "r1", "Wager", "10", "USD", "10", at),
+			wantStatus: IngestConflict,
+			wantStored: 1,
+		},
+		{
+			name:       "payout of the same round",
+			tx:         transaction(alice, "r1", "Payout", "20", "USD", "20", at),
+			wantStatus: IngestCreated,
+			wantStored: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryTransactionStore(stored)
+			service := NewTransactionService(store, nil, nil)
+
+			results, err := service.Create(context.Background(), []models.Transaction{tt.tx})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			result := results[0]
+			if result.Status != tt.wantStatus {
+				t.Fatalf("got status %s (%v), want %s", result.Status, result.Err, tt.wantStatus)
+			}
+
+			switch result.Status {
+			case IngestReplayed:
+				// The original is returned and nothing new is stored
+				if result.Transaction.ID != store.transactions[0].ID {
+					t.Errorf("got transaction %s, want the original %s", result.Transaction.ID.Hex(), store.transactions[0].ID.Hex())
+				}
+			case IngestConflict:
+				if result.Transaction == nil || result.Transaction.ID != store.transactions[0].ID {
+					t.Errorf("got %+v, want the conflicting stored transaction", result.Transaction)
+				}
+			}
+			if len(store.transactions) != tt.wantStored {
+				t.Errorf("got %d stored transactions, want %d", len(store.transactions), tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestTransactionServiceCreateBatchReplay(t *testing.T) {
+	store := NewMemoryTransactionStore()
+	service := NewTransactionService(store, nil, nil)
+	at := date("2024-03-01T12:00:00Z")
+
+	batch := func() []models.Transaction {
+		wager := transaction(alice, "r1", "Wager", "10", "USD", "10", at)
+		wager.IdempotencyKey = "wager-r1"
+		payout := transaction(alice, "r1", "Payout", "15", "USD", "15", at)
+		payout.IdempotencyKey = "payout-r1"
+		return []models.Transaction{wager, payout}
+	}
+
+	first, err := service.Create(context.Background(), batch())
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	retried, err := service.Create(context.Background(), batch())
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	for i := range first {
+		if first[i].Status != IngestCreated || retried[i].Status != IngestReplayed {
+			t.Errorf("item %d: got %s then %s, want created then replayed", i, first[i].Status, retried[i].Status)
+		}
+		if retried[i].Transaction.ID != first[i].Transaction.ID {
+			t.Errorf("item %d: replay returned %s, want %s", i, retried[i].Transaction.ID.Hex(), first[i].Transaction.ID.Hex())
+		}
+	}
+	if len(store.transactions) != 2 {
+		t.Errorf("got %d stored transactions, want 2", len(store.transactions))
+	}
+}
+
+func TestBuildTransactionRejectsFutureCreatedAt(t *testing.T) {
+	service := NewTransactionService(NewMemoryTransactionStore(), nil, nil)
+
+	tests := []struct {
+		name      string
+		createdAt time.Time
+		wantErr   string
+	}{
+		{name: "within the allowed skew", createdAt: time.Now().Add(time.Minute)},
+		{name: "in the past", createdAt: time.Now().AddDate(-1, 0, 0)},
+		{name: "far in the future", createdAt: time.Now().Add(time.Hour), wantErr: "createdAt must not be more than"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// usdAmount is given so no rate lookup is needed
+			_, err := service.BuildTransaction(context.Background(), models.TransactionRequest{
+				UserID:    alice.Hex(),
+				RoundID:   "r1",
+				Type:      "Wager",
+				Amount:    json.Number("10"),
+				Currency:  "USD",
+				USDAmount: json.Number("10"),
+				CreatedAt: &tt.createdAt,
+			})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("BuildTransaction: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
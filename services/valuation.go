@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"admin_statistics_api/models"
+)
+
+// Valuations accepted by the GGR and wager volume endpoints. booked keeps
+// the usdAmount stored with each transaction; spot and asof revalue native
+// totals at the rates in effect now or at a chosen instant.
+const (
+	ValuationBooked = "booked"
+	ValuationSpot   = "spot"
+	ValuationAsOf   = "asof"
+)
+
+// RevalueGrossGamingRevenue restates each currency's GGR at its USD rate
+// in effect at asOf, filling in Revaluation and summarising the totals
+func (s *StatisticsService) RevalueGrossGamingRevenue(ctx context.Context, results []models.GrossGamingRevenue, mode string, asOf time.Time) (*models.ValuationSummary, error) {
+	currencies := make([]string, len(results))
+	for i, ggr := range results {
+		currencies[i] = ggr.Currency
+	}
+	rates, summary, err := s.valuationRates(ctx, currencies, mode, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		results[i].Revaluation = revalue(rates[results[i].Currency], results[i].Amount, results[i].USDValue)
+		addRevaluation(summary, results[i].USDValue, results[i].Revaluation)
+	}
+	return summary, nil
+}
+
+// RevalueWagerVolume restates every bucket's volume at the USD rate of its
+// currency in effect at asOf, so all buckets share one rate per currency
+func (s *StatisticsService) RevalueWagerVolume(ctx context.Context, results []models.WagerVolume, mode string, asOf time.Time) (*models.ValuationSummary, error) {
+	currencies := make([]string, len(results))
+	for i, volume := range results {
+		currencies[i] = volume.Currency
+	}
+	rates, summary, err := s.valuationRates(ctx, currencies, mode, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		results[i].Revaluation = revalue(rates[results[i].Currency], results[i].Amount, results[i].USDValue)
+		addRevaluation(summary, results[i].USDValue, results[i].Revaluation)
+	}
+	return summary, nil
+}
+
+// RevalueDailyWagerVolume is RevalueWagerVolume for the daily volume shape
+func (s *StatisticsService) RevalueDailyWagerVolume(ctx context.Context, results []models.DailyWagerVolume, mode string, asOf time.Time) (*models.ValuationSummary, error) {
+	currencies := make([]string, len(results))
+	for i, volume := range results {
+		currencies[i] = volume.Currency
+	}
+	rates, summary, err := s.valuationRates(ctx, currencies, mode, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		results[i].Revaluation = revalue(rates[results[i].Currency], results[i].Amount, results[i].USDValue)
+		addRevaluation(summary, results[i].USDValue, results[i].Revaluation)
+	}
+	return summary, nil
+}
+
+// addRevaluation adds one revalued result to the totals of a summary
+func addRevaluation(summary *models.ValuationSummary, booked models.Decimal, revaluation *models.Revaluation) {
+	summary.BookedUSD = summary.BookedUSD.Add(booked)
+	summary.RevaluedUSD = summary.RevaluedUSD.Add(revaluation.RevaluedUSD)
+	summary.FXGainLoss = summary.FXGainLoss.Add(revaluation.FXGainLoss)
+}
+
+// valuationRates looks up the USD rate in effect at asOf of every distinct
+// currency, failing with ErrRateNotFound when one has none
+func (s *StatisticsService) valuationRates(ctx context.Context, currencies []string, mode string, asOf time.Time) (map[string]float64, *models.ValuationSummary, error) {
+	summary := &models.ValuationSummary{
+		Mode:  mode,
+		AsOf:  asOf,
+		Rates: []models.ExchangeRate{},
+	}
+
+	rates := make(map[string]float64)
+	for _, currency := range currencies {
+		if _, ok := rates[currency]; ok {
+			continue
+		}
+		if currency == QuoteUSD {
+			rates[currency] = 1
+			continue
+		}
+		rate, err := s.rates.RateAt(ctx, currency, QuoteUSD, asOf)
+		if errors.Is(err, ErrRateNotFound) {
+			return nil, nil, rateNotFound(currency, QuoteUSD, asOf)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		rates[currency] = rate.Rate
+		summary.Rates = append(summary.Rates, rate)
+	}
+
+	sort.Slice(summary.Rates, func(i, j int) bool {
+		return summary.Rates[i].Base < summary.Rates[j].Base
+	})
+	return rates, summary, nil
+}
+
+func revalue(rate float64, amount, booked models.Decimal) *models.Revaluation {
+	revalued := amount.MulFloat(rate)
+	return &models.Revaluation{
+		Rate:        rate,
+		RevaluedUSD: revalued,
+		FXGainLoss:  revalued.Sub(booked),
+	}
+}
@@ -0,0 +1,17 @@
+package utils
+
+import (
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DecimalToFloat converts a Decimal128 to float64, returning 0 for values
+// that cannot be represented (NaN, Inf or malformed)
+func DecimalToFloat(d primitive.Decimal128) float64 {
+	f, err := strconv.ParseFloat(d.String(), 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// LoadTimezone resolves an IANA time zone name such as "Europe/Malta".
+// "Local" is rejected because its meaning depends on the server and MongoDB
+// would not recognise it.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("%q is not an IANA time zone name", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return loc, nil
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// CurrencySet reports which currency codes are registered and which of
+// those may be used for new transactions
+type CurrencySet interface {
+	Known(code string) bool
+	Enabled(code string) bool
+}
+
+// NewValidator returns a validator that reports fields by their JSON names
+// and checks currency tags against currencies
+func NewValidator(currencies CurrencySet) *validator.Validate {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	RegisterCurrencyValidations(validate, currencies)
+	return validate
+}
+
+// RegisterCurrencyValidations adds the currency tag, which accepts enabled
+// currencies, and the known_currency tag, which also accepts disabled ones
+func RegisterCurrencyValidations(validate *validator.Validate, currencies CurrencySet) {
+	validate.RegisterValidation("currency", func(fl validator.FieldLevel) bool {
+		return currencies.Enabled(fl.Field().String())
+	})
+	validate.RegisterValidation("known_currency", func(fl validator.FieldLevel) bool {
+		return currencies.Known(fl.Field().String())
+	})
+}
+
+// ValidationMessages turns validator errors into readable messages
+func ValidationMessages(err error) []string {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return []string{err.Error()}
+	}
+
+	messages := make([]string, 0, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		switch fieldErr.Tag() {
+		case "required":
+			messages = append(messages, fmt.Sprintf("%s is required", fieldErr.Field()))
+		case "oneof":
+			messages = append(messages, fmt.Sprintf("%s must be one of [%s]", fieldErr.Field(), fieldErr.Param()))
+		case "currency":
+			messages = append(messages, fmt.Sprintf("%s must be an enabled currency", fieldErr.Field()))
+		case "known_currency":
+			messages = append(messages, fmt.Sprintf("%s must be a registered currency", fieldErr.Field()))
+		case "max":
+			messages = append(messages, fmt.Sprintf("%s must be at most %s characters", fieldErr.Field(), fieldErr.Param()))
+		default:
+			messages = append(messages, fmt.Sprintf("%s failed the %s check", fieldErr.Field(), fieldErr.Tag()))
+		}
+	}
+	return messages
+}